@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// benchCase is one line of the bench cases file and what running it cost
+type benchCase struct {
+	line   int
+	inputs vm.Values
+	cycles int
+	err    error
+}
+
+// benchFile runs the -file program once for every line of -input-file, each
+// line holding the INP values for one run, and prints the cycles each run took
+func benchFile() {
+	requireFile("bench")
+	if *inputFile == "" {
+		fmt.Fprintln(os.Stderr, "bench needs input cases: lmc bench -file prog.lmc -input-file cases.txt")
+		os.Exit(1)
+	}
+
+	program := compileFile()
+	cases, err := readBenchCases(*inputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -input-file: %v\n", err)
+		os.Exit(1)
+	}
+	if len(cases) == 0 {
+		fmt.Fprintf(os.Stderr, "error: -input-file: %s has no cases\n", *inputFile)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	for i := range cases {
+		// Every case starts from a freshly loaded program so one run cannot
+		// leave data behind for the next
+		cpu := newCPU(program)
+		inputs := append(vm.Values(nil), cases[i].inputs...)
+		cpu.Input = &inputs
+		cases[i].err = cpu.RunContext(ctx)
+		cases[i].cycles = cpu.Cost
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "interrupted")
+			os.Exit(130)
+		}
+	}
+
+	if !printBench(cases) {
+		os.Exit(1)
+	}
+}
+
+// readBenchCases reads one case per non-blank line of path
+func readBenchCases(path string) ([]benchCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cases []benchCase
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		c := benchCase{line: line}
+		for i, field := range fields {
+			value, err := vm.ParseValue(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: line %d, value %d: %v", path, line, i+1, err)
+			}
+			c.inputs = append(c.inputs, value)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
+
+// printBench prints a row per case followed by the average, minimum and
+// maximum cycles of the cases that halted. It reports whether every case did
+func printBench(cases []benchCase) bool {
+	fmt.Printf("%-6s  %-6s  %s\n", "Case", "Cycles", "Inputs")
+
+	ok := true
+	total, count, min, max := 0, 0, 0, 0
+	for i, c := range cases {
+		inputs := make([]string, len(c.inputs))
+		for j, value := range c.inputs {
+			inputs[j] = fmt.Sprint(value)
+		}
+		row := fmt.Sprintf("%-6d  %-6d  %s", i+1, c.cycles, strings.Join(inputs, " "))
+		if c.err != nil {
+			ok = false
+			fmt.Printf("%s  error: %v\n", row, c.err)
+			continue
+		}
+		fmt.Println(row)
+
+		if count == 0 || c.cycles < min {
+			min = c.cycles
+		}
+		if c.cycles > max {
+			max = c.cycles
+		}
+		total += c.cycles
+		count++
+	}
+
+	fmt.Println()
+	fmt.Printf("cases: %d  failed: %d\n", len(cases), len(cases)-count)
+	if count > 0 {
+		fmt.Printf("cycles: average %.1f  min %d  max %d\n", float64(total)/float64(count), min, max)
+	}
+	return ok
+}
@@ -0,0 +1,148 @@
+package compiler
+
+import (
+	"sort"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// AnalysisReport is what can be told about a program without running it. The
+// counts are of instructions in the program, not of how often they will run
+type AnalysisReport struct {
+	Inputs     int
+	Outputs    int
+	Halts      bool
+	Referenced []int
+	// Mix counts the code cells holding each instruction
+	Mix map[models.Opcode]int
+	// CodeCells and DataCells count the mailboxes assembled as code and as DAT
+	CodeCells int
+	DataCells int
+	// Warnings are guesses at logic mistakes, such as a program that reads
+	// input but never shows a result
+	Warnings []Warning
+}
+
+// Analyze counts the I/O instructions of a program, finds the mailboxes its
+// instructions refer to and checks that it can halt. It also warns when the
+// accumulator is used before anything has been put in it, unless accSeeded
+// says the run gives it a value first, and when a loop plainly has no way out
+func Analyze(program *models.Program, accSeeded bool) AnalysisReport {
+	report := AnalysisReport{Mix: make(map[models.Opcode]int)}
+	referenced := make(map[int]bool)
+
+	for address := 0; address < program.Size; address++ {
+		source, ok := program.Source[address]
+		if !ok {
+			continue
+		}
+		if source.Data {
+			report.DataCells++
+			continue
+		}
+
+		instruction := program.Dialect.Decode(program.RAM[address])
+		report.CodeCells++
+		report.Mix[instruction.Opcode]++
+		switch instruction.Opcode {
+		case models.INP:
+			report.Inputs++
+		case models.OUT:
+			report.Outputs++
+		case models.HLT:
+			report.Halts = true
+		default:
+			// Extensions such as OTC have no operand to refer with
+			if instruction.Opcode != models.DAT && instruction.Opcode.HasOperand() {
+				referenced[instruction.Operand] = true
+			}
+		}
+	}
+
+	for address := range referenced {
+		report.Referenced = append(report.Referenced, address)
+	}
+	sort.Ints(report.Referenced)
+
+	if warning, ok := unloadedAccumulator(program); ok && !accSeeded {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	report.Warnings = append(report.Warnings, endlessLoops(program)...)
+
+	switch {
+	case report.Inputs > 0 && report.Outputs == 0:
+		report.Warnings = append(report.Warnings, Warning{Msg: "program reads input but never outputs"})
+	case report.Outputs > 0 && report.Inputs == 0:
+		report.Warnings = append(report.Warnings, Warning{Msg: "program outputs but never reads input"})
+	}
+
+	return report
+}
+
+// endlessLoops finds the BRA instructions that branch back to an earlier
+// mailbox with nothing between the target and the BRA that could leave the
+// loop: no BRZ, BRP or HLT, and no other branch out of it. Loops that depend
+// on data or on the program rewriting itself are not caught
+func endlessLoops(program *models.Program) []Warning {
+	var warnings []Warning
+	for address := 0; address < program.Size; address++ {
+		source, ok := program.Source[address]
+		if !ok || source.Data || source.Macro {
+			continue
+		}
+		instruction := program.Dialect.Decode(program.RAM[address])
+		if instruction.Opcode != models.BRA || instruction.Operand > address {
+			continue
+		}
+		if !loopHasExit(program, instruction.Operand, address) {
+			warnings = append(warnings, Warning{File: source.File, Line: source.Line, Msg: "unconditional backward branch with no exit, possible infinite loop"})
+		}
+	}
+	return warnings
+}
+
+// loopHasExit reports whether any of the mailboxes from start up to the BRA
+// at end could stop the program or branch somewhere else. Data or unused
+// mailboxes in the way count as an exit, since what they do is anyone's guess
+func loopHasExit(program *models.Program, start, end int) bool {
+	for address := start; address < end; address++ {
+		source, ok := program.Source[address]
+		if !ok || source.Data {
+			return true
+		}
+		switch instruction := program.Dialect.Decode(program.RAM[address]); instruction.Opcode {
+		case models.BRZ, models.BRP, models.HLT:
+			return true
+		case models.BRA:
+			if instruction.Operand < start || instruction.Operand > end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unloadedAccumulator finds an instruction that uses the accumulator before
+// any LDA or INP has set it, relying on it starting at 0. It follows the code
+// from mailbox 0 in address order and ignores branches, so it gives up at the
+// first BRA or HLT, beyond which the order says nothing about what runs first
+func unloadedAccumulator(program *models.Program) (Warning, bool) {
+	for address := 0; address < program.Size; address++ {
+		source, ok := program.Source[address]
+		if !ok || source.Data {
+			return Warning{}, false
+		}
+		// CALL saves the accumulator whether or not it was set
+		if source.Macro {
+			continue
+		}
+
+		switch program.Dialect.Decode(program.RAM[address]).Opcode {
+		case models.LDA, models.INP, models.BRA, models.HLT:
+			return Warning{}, false
+		case models.ADD, models.SUB, models.STA, models.OUT:
+			return Warning{File: source.File, Line: source.Line, Msg: "accumulator used before being loaded"}, true
+		}
+	}
+	return Warning{}, false
+}
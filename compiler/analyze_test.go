@@ -0,0 +1,35 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnloadedAccumulator(t *testing.T) {
+	tests := []struct {
+		src       string
+		accSeeded bool
+		warned    bool
+	}{
+		{src: "ADD one\nOUT\nHLT\none DAT 1", warned: true},
+		{src: "ADD one\nOUT\nHLT\none DAT 1", accSeeded: true},
+		{src: "LDA one\nADD one\nOUT\nHLT\none DAT 1"},
+		{src: "INP\nOUT\nHLT"},
+	}
+	for _, test := range tests {
+		program, err := Assemble(strings.NewReader(test.src), Options{})
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		warned := false
+		for _, warning := range Analyze(program, test.accSeeded).Warnings {
+			if warning.Msg == "accumulator used before being loaded" {
+				warned = true
+			}
+		}
+		if warned != test.warned {
+			t.Errorf("%q seeded %v: warned %v, want %v", test.src, test.accSeeded, warned, test.warned)
+		}
+	}
+}
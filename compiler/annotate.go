@@ -0,0 +1,73 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Annotate writes the source the program was assembled from with the mailbox
+// each line went into and its machine code added as a comment, e.g.
+// LDA count ; @00 = 510. The result still assembles to the same program.
+// Lines that fill several mailboxes, such as CALL or DAT 0 * 20, get the
+// range, e.g. @20-39, or ranges when the cells are apart. Blank lines,
+// comments and lines from included files are written as they are
+func Annotate(r io.Reader, program *models.Program, w io.Writer) error {
+	lines, err := readLines(r, "")
+	if err != nil {
+		return err
+	}
+
+	addresses := make(map[int][]int)
+	for _, address := range program.RAM.SortedAddresses() {
+		if source, ok := program.Source[address]; ok && source.File == "" {
+			addresses[source.Line] = append(addresses[source.Line], address)
+		}
+	}
+
+	// Line the comments up after the longest annotated line
+	width := 0
+	for _, l := range lines {
+		if text := strings.TrimRight(l.text, " \t"); len(addresses[l.line]) > 0 && len(text) > width {
+			width = len(text)
+		}
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, l := range lines {
+		cells := addresses[l.line]
+		if len(cells) == 0 {
+			fmt.Fprintln(buf, l.text)
+			continue
+		}
+
+		text := strings.TrimRight(l.text, " \t")
+		if len(cells) == 1 {
+			fmt.Fprintf(buf, "%-*s   ; @%02d = %03d\n", width, text, cells[0], program.RAM[cells[0]])
+		} else {
+			fmt.Fprintf(buf, "%-*s   ; @%s\n", width, text, AddressRanges(cells))
+		}
+	}
+	return buf.Flush()
+}
+
+// AddressRanges writes sorted addresses as runs, e.g. 01-06,15
+func AddressRanges(addresses []int) string {
+	var runs []string
+	for i := 0; i < len(addresses); {
+		j := i
+		for j+1 < len(addresses) && addresses[j+1] == addresses[j]+1 {
+			j++
+		}
+		if i == j {
+			runs = append(runs, fmt.Sprintf("%02d", addresses[i]))
+		} else {
+			runs = append(runs, fmt.Sprintf("%02d-%02d", addresses[i], addresses[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(runs, ",")
+}
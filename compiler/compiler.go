@@ -2,68 +2,727 @@
 package compiler
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/sparrowTek/LittleManComputer-CLI/models"
 )
 
-// CompileFromFile compiles the assembly code for the given file
-func CompileFromFile(filePath string) models.RAM {
-	// file, err := os.Open(filePath)
-	// defer file.Close()
+// Error is an assembly error tied to a line of the source
+type Error struct {
+	// File is the included file the error is in, "" for the file being
+	// assembled
+	File string
+	Line int
+	// Col is where the offending token starts on the line, counting from 1.
+	// It is 0 when the error is about the line as a whole
+	Col int
+	// Text is the source line the error was found on
+	Text string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s line %d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Underline shows the source line with the offending token marked below it,
+// or "" when the error has no column
+func (e *Error) Underline() string {
+	if e.Col < 1 || e.Col > len(e.Text) {
+		return ""
+	}
+
+	width := strings.IndexFunc(e.Text[e.Col-1:], unicode.IsSpace)
+	if width < 0 {
+		width = len(e.Text) - e.Col + 1
+	}
+	// Tabs before the token are kept so the caret lines up however wide
+	// the terminal draws them
+	indent := strings.Map(func(c rune) rune {
+		if c == '\t' {
+			return c
+		}
+		return ' '
+	}, e.Text[:e.Col-1])
+	return e.Text + "\n" + indent + "^" + strings.Repeat("~", width-1)
+}
+
+// Options change how the assembler treats a program
+type Options struct {
+	// Strict requires halts to be written as HLT and rejects programs whose
+	// code can run into data cells or unassembled memory. Lint warnings
+	// become errors
+	Strict bool
+	// Size is the number of mailboxes, up to models.MaxSize. Zero means the
+	// standard 100
+	Size int
+	// Dialect is the instruction set to accept. The zero Dialect means
+	// models.Standard
+	Dialect models.Dialect
+	// NoIncludes makes every #include an error instead of reading the file
+	// it names, for source from someone who should not see the host's
+	// files, such as a student submission on a grading server
+	NoIncludes bool
+}
+
+// equ is the directive that names a constant, e.g. MAX EQU 99. It is not an
+// instruction and takes no mailbox
+const equ models.Opcode = "EQU"
+
+// machineCode marks a line written as a bare number, e.g. 901. The number goes
+// into the mailbox as it is and the mailbox counts as code, not data
+const machineCode models.Opcode = "machine code"
+
+// symbols are the names the first pass defines
+type symbols struct {
+	labels    map[string]int
+	constants map[string]int
+	// locals are the definitions of each local label, such as 1:, in source
+	// order
+	locals map[string][]localLabel
+}
 
-	// if err != nil {
-	// 	// Handle open file error
-	// 	fmt.Fprintf(os.Stderr, "compile error: %v\n", err)
-	// }
+// statement is one line of source that assembles into a mailbox
+type statement struct {
+	// file is the included file the statement is from, "" for the main file
+	file string
+	line int
+	raw  string
+	text string
 
-	// parse the assembly code in the file
-	// b, err := ioutil.ReadAll(file)
-	// fmt.Print(b)
+	label      string
+	labelCol   int
+	opcode     models.Opcode
+	opcodeCol  int
+	operand    string
+	operandCol int
+	// value is the number on a machine code line
+	value models.Register
+	// macro is the CALL or RET the statement was expanded from
+	macro string
+	// repeat is how many mailboxes a DAT such as DAT 0 * 20 fills, 0 for one
+	repeat int
+	// seq is the position of the line in the source, for local labels
+	seq int
+	// unit is the linked file the statement is from and base the mailbox
+	// that file starts at, which its numeric addresses are relative to. Both
+	// are 0 outside Link
+	unit int
+	base int
 
-	printRegisters()
+	address int
+}
+
+// errorAt builds an error pointing at a column of the statement's line
+func (s statement) errorAt(col int, format string, args ...interface{}) *Error {
+	return &Error{File: s.file, Line: s.line, Col: col, Text: s.raw, Msg: fmt.Sprintf(format, args...)}
+}
+
+// token is a word of a source line and the column it starts in
+type token struct {
+	text string
+	col  int
+}
 
-	return make(map[int]models.Register)
+// tokenize splits a line into words at any run of spaces and tabs, remembering
+// the column each starts in
+func tokenize(line string) []token {
+	var tokens []token
+	start := -1
+	for i, c := range line {
+		switch {
+		case unicode.IsSpace(c) && start >= 0:
+			tokens = append(tokens, token{text: line[start:i], col: start + 1})
+			start = -1
+		case !unicode.IsSpace(c) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: line[start:], col: start + 1})
+	}
+	return tokens
+}
+
+// CompileFromFile compiles the assembly code for the given file. Files it
+// includes are found relative to its directory
+func CompileFromFile(filePath string, opts Options) (*models.Program, error) {
+	lines, err := readSource(filePath, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	return assemble(lines, opts)
+}
+
+// readSource reads the lines of the source file at path with its includes
+// expanded, or rejected under opts.NoIncludes. name is what the lines give as
+// their file, "" for the file being assembled
+func readSource(path, name string, opts Options) ([]sourceLine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines, err := readLines(file, name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.NoIncludes {
+		return lines, rejectIncludes(lines)
+	}
+	visiting := make(map[string]bool)
+	if abs, err := filepath.Abs(path); err == nil {
+		visiting[abs] = true
+	}
+	return expandIncludes(lines, filepath.Dir(path), visiting)
+}
+
+// AssembleString assembles source held in a string with the default options
+func AssembleString(src string) (models.RAM, error) {
+	program, err := Assemble(strings.NewReader(src), Options{})
+	if err != nil {
+		return nil, err
+	}
+	return program.RAM, nil
 }
 
-// CompileTerminalInput compiles the assembly code entered by the user in their terminal emulator
-func CompileTerminalInput() {
-	buf := bufio.NewReader(os.Stdin)
-	fmt.Print("> ")
-	sentence, err := buf.ReadBytes('\n')
+// Assemble turns LMC assembly source into a program. Files it includes are
+// found relative to the working directory, unless opts.NoIncludes turns
+// #include off
+func Assemble(r io.Reader, opts Options) (*models.Program, error) {
+	lines, err := readLines(r, "")
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
+	}
+	if opts.NoIncludes {
+		err = rejectIncludes(lines)
 	} else {
-		fmt.Println(string(sentence))
-	}
-
-	printRegisters()
-}
-
-func printRegisters() {
-	fmt.Println("Memory Registers")
-	fmt.Println("")
-	fmt.Println("   0       1       2       3       4       5       6       7       8       9")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
-	fmt.Println("-------------------------------------------------------------------------------")
-	fmt.Println("  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  |  000  ")
+		lines, err = expandIncludes(lines, ".", make(map[string]bool))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assemble(lines, opts)
+}
+
+// assemble turns lines of source into a program. The first pass gives every
+// statement a mailbox and records the labels, the second resolves operands
+func assemble(lines []sourceLine, opts Options) (*models.Program, error) {
+	size := opts.Size
+	if size == 0 {
+		size = models.MaxSize
+	}
+	if size < 1 || size > models.MaxSize {
+		return nil, fmt.Errorf("memory size %d must be from 1 to %d mailboxes", size, models.MaxSize)
+	}
+
+	dialect := opts.Dialect
+	if dialect.Name == "" {
+		dialect = models.Standard
+	}
+
+	lines, err := expandMacros(lines)
+	if err != nil {
+		return nil, err
+	}
+	statements, syms, meta, err := firstPass(lines, size, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Strict {
+		if err := checkStrict(statements); err != nil {
+			return nil, err
+		}
+	}
+
+	program := &models.Program{
+		RAM:       make(models.RAM),
+		Size:      size,
+		Labels:    syms.labels,
+		Constants: syms.constants,
+		Source:    make(map[int]models.SourceLine),
+		Title:     meta.title,
+		Author:    meta.author,
+		Dialect:   dialect,
+	}
+
+	for _, s := range statements {
+		value, err := encode(s, syms, size, dialect)
+		if err != nil {
+			return nil, err
+		}
+		program.RAM[s.address] = value
+		program.Source[s.address] = models.SourceLine{
+			File:    s.file,
+			Line:    s.line,
+			Col:     s.opcodeCol,
+			Text:    s.text,
+			Operand: s.operand,
+			Data:    s.opcode == models.DAT,
+			Macro:   s.macro != "",
+		}
+	}
+
+	if opts.Strict {
+		if warnings := Lint(program); len(warnings) > 0 {
+			return nil, &Error{File: warnings[0].File, Line: warnings[0].Line, Msg: warnings[0].Msg}
+		}
+	}
+
+	return program, nil
+}
+
+// checkStrict makes sure execution can only stop at an explicit HLT. Code must
+// not run on into a DAT cell or past the end of the program, and branches must
+// not target mailboxes the program never assembled. Only a HLT written as a
+// mnemonic counts as the program's halt; machine code such as 000 stops the
+// machine too, but reads as data
+func checkStrict(statements []statement) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	halts := false
+	for i, s := range statements {
+		written := s.opcode
+		// Machine code is checked as the instruction it decodes to
+		if instruction := models.Decode(s.value); s.opcode == machineCode && instruction.Opcode != models.DAT {
+			s.opcode, s.operand = instruction.Opcode, strconv.Itoa(instruction.Operand)
+		}
+
+		switch s.opcode {
+		case models.DAT:
+			continue
+		case models.HLT:
+			if written == models.HLT {
+				halts = true
+			}
+			continue
+		case models.BRA, models.BRZ, models.BRP:
+			if address, err := parseNumber(s.operand); err == nil && address >= len(statements) {
+				return &Error{File: s.file, Line: s.line, Msg: fmt.Sprintf("branch to blank mailbox %d", address)}
+			}
+			if s.opcode == models.BRA {
+				continue
+			}
+		}
+
+		// Everything else carries on to the next mailbox
+		if i+1 == len(statements) {
+			return &Error{File: s.file, Line: s.line, Msg: "execution runs past the end of the program, end the code with HLT"}
+		}
+		if next := statements[i+1]; next.opcode == models.DAT {
+			return &Error{File: s.file, Line: s.line, Msg: fmt.Sprintf("execution falls into the DAT on line %d, add a HLT before the data", next.line)}
+		}
+	}
+
+	if !halts {
+		return &Error{File: statements[len(statements)-1].file, Line: statements[len(statements)-1].line, Msg: "program has no HLT"}
+	}
+	return nil
+}
+
+// firstPass parses every line and assigns mailbox addresses, labels and
+// constants. A label on a line of its own belongs to the next instruction, so
+// several labels can name the same mailbox. Directives are read from the
+// comments before the first line of code
+func firstPass(lines []sourceLine, size int, dialect models.Dialect) ([]statement, symbols, metadata, error) {
+	var statements []statement
+	var pending []statement
+	var meta metadata
+	syms := symbols{labels: make(map[string]int), constants: make(map[string]int), locals: make(map[string][]localLabel)}
+	labels, constants := syms.labels, syms.constants
+
+	header := true
+	// Where each linked file starts, the mailbox its first line would go in
+	bases := make(map[int]int)
+	for i, l := range lines {
+		if _, ok := bases[l.unit]; !ok {
+			bases[l.unit] = len(statements)
+		}
+		s, ok, err := parseLine(l, dialect)
+		if err != nil {
+			return nil, syms, meta, err
+		}
+		s.seq = i
+		s.base = bases[l.unit]
+		if !ok {
+			if header && l.file == "" {
+				meta.parseDirective(l.text)
+			}
+			continue
+		}
+		header = false
+
+		if s.opcode == equ {
+			if isLocalLabel(s.label) {
+				return nil, syms, meta, s.errorAt(s.labelCol, "local label %s cannot name a constant", s.label)
+			}
+			if _, exists := constants[s.label]; exists {
+				return nil, syms, meta, s.errorAt(s.labelCol, "constant '%s' is already defined", s.label)
+			}
+			if _, exists := labels[s.label]; exists {
+				return nil, syms, meta, s.errorAt(s.labelCol, "'%s' is already a label", s.label)
+			}
+			value, err := parseNumber(s.operand)
+			if errors.Is(err, strconv.ErrRange) || err == nil && value > 999 {
+				return nil, syms, meta, s.errorAt(s.operandCol, "EQU value %s exceeds maximum 999", s.operand)
+			}
+			if err != nil || value < 0 {
+				return nil, syms, meta, s.errorAt(s.operandCol, "EQU value '%s' must be a number from 0 to 999", s.operand)
+			}
+			constants[s.label] = value
+			continue
+		}
+
+		if isLocalLabel(s.label) {
+			// Local labels can be defined again and again
+			name := strings.TrimSuffix(s.label, ":")
+			syms.locals[name] = append(syms.locals[name], localLabel{seq: i, unit: s.unit, address: len(statements)})
+		} else if s.label != "" {
+			if _, exists := labels[s.label]; exists {
+				return nil, syms, meta, s.errorAt(s.labelCol, "duplicate label '%s'", s.label)
+			}
+			if _, exists := constants[s.label]; exists {
+				return nil, syms, meta, s.errorAt(s.labelCol, "'%s' is already a constant", s.label)
+			}
+			labels[s.label] = len(statements)
+		}
+
+		if s.opcode == "" {
+			pending = append(pending, s)
+			continue
+		}
+		pending = nil
+
+		// A repeated DAT takes a mailbox for each copy, the label naming the first
+		for n := 0; n < max(s.repeat, 1); n++ {
+			s.address = len(statements)
+			if s.address >= size {
+				return nil, syms, meta, &Error{File: s.file, Line: s.line, Msg: fmt.Sprintf("program does not fit in %d mailboxes", size)}
+			}
+			statements = append(statements, s)
+		}
+	}
+	// A label after the last instruction would name a mailbox outside the program
+	if len(pending) > 0 {
+		return nil, syms, meta, &Error{File: pending[0].file, Line: pending[0].line, Msg: fmt.Sprintf("label '%s' is at the end of the program with no instruction after it", pending[0].label)}
+	}
+
+	return statements, syms, meta, nil
+}
+
+// scanLines splits source into lines ending in LF, CRLF or a lone CR, so files
+// saved on Windows or old Macs number their lines the same as Unix ones
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, c := range data {
+		switch {
+		case c == '\n':
+			return i + 1, data[:i], nil
+		case c != '\r':
+		case i+1 < len(data):
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		case atEOF:
+			return i + 1, data[:i], nil
+		default:
+			// Wait for the next byte to tell CRLF from a lone CR
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// parseLine splits a line into label, mnemonic and operand. It reports false
+// for lines that hold nothing but whitespace and comments. A line holding only
+// a label comes back with an empty opcode
+func parseLine(l sourceLine, dialect models.Dialect) (statement, bool, error) {
+	s := statement{file: l.file, line: l.line, raw: l.text, text: strings.TrimSpace(l.text), macro: l.macro, unit: l.unit}
+
+	tokens := tokenize(stripComment(l.text))
+	if len(tokens) == 0 {
+		return s, false, nil
+	}
+	if models.Opcode(strings.ToUpper(tokens[0].text)) == equ {
+		return s, false, s.errorAt(tokens[0].col, "EQU needs a name, e.g. MAX EQU 99")
+	}
+
+	if _, ok := lookupOpcode(dialect, tokens[0].text); !ok && !isNumber(tokens[0].text) {
+		if !isLabel(tokens[0].text) && !isLocalLabel(tokens[0].text) {
+			return s, false, s.errorAt(tokens[0].col, "unknown mnemonic '%s'", tokens[0].text)
+		}
+		s.label, s.labelCol = tokens[0].text, tokens[0].col
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return s, true, nil
+		}
+
+		if models.Opcode(strings.ToUpper(tokens[0].text)) == equ {
+			if len(tokens) != 2 {
+				return s, false, s.errorAt(tokens[0].col, "EQU needs exactly one value")
+			}
+			s.opcode, s.opcodeCol = equ, tokens[0].col
+			s.operand, s.operandCol = tokens[1].text, tokens[1].col
+			return s, true, nil
+		}
+	}
+
+	if isNumber(tokens[0].text) {
+		return parseMachineCode(s, tokens)
+	}
+	// Two mnemonics in a row are almost always a label named after one,
+	// either the first word or, after an instruction that takes one, the
+	// operand. A label is followed by more, or by DAT or EQU
+	if opcode, ok := lookupOpcode(dialect, tokens[0].text); ok && len(tokens) > 1 && isReserved(dialect, tokens[1].text) {
+		declared := models.Opcode(strings.ToUpper(tokens[1].text))
+		if opcode.HasOperand() && len(tokens) == 2 && declared != models.DAT && declared != equ {
+			return s, false, s.errorAt(tokens[1].col, "%s", reservedLabel(dialect, tokens[1].text))
+		}
+		return s, false, s.errorAt(tokens[0].col, "%s", reservedLabel(dialect, tokens[0].text))
+	}
+
+	opcode, ok := lookupOpcode(dialect, tokens[0].text)
+	if !ok {
+		return s, false, s.errorAt(tokens[0].col, "unknown mnemonic '%s'", tokens[0].text)
+	}
+	s.opcode, s.opcodeCol = opcode, tokens[0].col
+	tokens = tokens[1:]
+
+	// DAT value * count fills count mailboxes with the value
+	if opcode == models.DAT && len(tokens) == 3 && tokens[1].text == "*" {
+		repeat, err := strconv.Atoi(tokens[2].text)
+		if err != nil || repeat < 1 || repeat > models.MaxSize {
+			return s, false, s.errorAt(tokens[2].col, "DAT repeat count '%s' must be a number from 1 to %d", tokens[2].text, models.MaxSize)
+		}
+		s.operand, s.operandCol = tokens[0].text, tokens[0].col
+		s.repeat = repeat
+		return s, true, nil
+	}
+
+	// Check the operand count against what the instruction takes
+	switch {
+	case !opcode.HasOperand() && len(tokens) > 0:
+		return s, false, s.errorAt(tokens[0].col, "%s takes no operand", opcode)
+	case opcode.HasOperand() && opcode != models.DAT && len(tokens) == 0:
+		return s, false, s.errorAt(s.opcodeCol, "%s requires an operand", opcode)
+	case len(tokens) > 1:
+		return s, false, s.errorAt(tokens[1].col, "unexpected '%s' after the operand", tokens[1].text)
+	case len(tokens) == 1:
+		s.operand, s.operandCol = tokens[0].text, tokens[0].col
+	}
+
+	return s, true, nil
+}
+
+// check encodes an instruction the statement assembled to, making sure the
+// value decodes back to the same instruction
+func (s statement) check(instruction models.Instruction) (models.Register, error) {
+	value, err := instruction.EncodeChecked()
+	if err != nil {
+		return 0, s.errorAt(s.opcodeCol, "%v", err)
+	}
+	return value, nil
+}
+
+// parseMachineCode finishes a line whose instruction is written as a number
+func parseMachineCode(s statement, tokens []token) (statement, bool, error) {
+	value, err := strconv.Atoi(tokens[0].text)
+	if errors.Is(err, strconv.ErrRange) || err == nil && value > 999 {
+		return s, false, s.errorAt(tokens[0].col, "machine code %s exceeds maximum 999", tokens[0].text)
+	}
+	if err != nil {
+		return s, false, s.errorAt(tokens[0].col, "machine code %s must be from 000 to 999", tokens[0].text)
+	}
+	if len(tokens) > 1 {
+		return s, false, s.errorAt(tokens[1].col, "unexpected '%s' after the machine code", tokens[1].text)
+	}
+
+	s.opcode, s.opcodeCol = machineCode, tokens[0].col
+	s.value = models.Register(value)
+	return s, true, nil
+}
+
+// encode resolves the statement's operand and returns its mailbox value. An
+// operand can be a number, in decimal or hexadecimal, a constant or a label;
+// numbers and constants must fit the instruction, a mailbox address or a DAT
+// value
+func encode(s statement, syms symbols, size int, dialect models.Dialect) (models.Register, error) {
+	if s.opcode == machineCode {
+		return s.value, nil
+	}
+	if code, ok := dialect.Extensions[s.opcode]; ok {
+		return code, nil
+	}
+	if s.operand == "" {
+		return s.check(models.Instruction{Opcode: s.opcode})
+	}
+
+	max, kind := size-1, "operand"
+	if s.opcode == models.DAT {
+		max, kind = 999, "value"
+	}
+
+	operand, err := parseNumber(s.operand)
+	if errors.Is(err, strconv.ErrRange) {
+		// Too many digits even to convert, let alone to fit
+		return 0, s.errorAt(s.operandCol, "%s %s exceeds maximum %d", kind, s.operand, max)
+	}
+	if err != nil {
+		if value, ok := syms.constants[s.operand]; ok {
+			if value > max {
+				return 0, s.errorAt(s.operandCol, "constant '%s' is %d, exceeds maximum %d", s.operand, value, max)
+			}
+			return s.check(models.Instruction{Opcode: s.opcode, Operand: value})
+		}
+
+		address, ok := syms.labels[s.operand]
+		if !ok && isLocalRef(s.operand) {
+			if address, err = resolveLocal(s, syms); err != nil {
+				return 0, err
+			}
+			ok = true
+		}
+		if !ok && strings.HasPrefix(strings.ToLower(s.operand), "0x") {
+			return 0, s.errorAt(s.operandCol, "'%s' is not a hexadecimal number", s.operand)
+		}
+		if !ok {
+			if suggestion, found := closestName(s.operand, syms); found {
+				return 0, s.errorAt(s.operandCol, "undefined label '%s' (did you mean '%s'?)", s.operand, suggestion)
+			}
+			return 0, s.errorAt(s.operandCol, "undefined label '%s'", s.operand)
+		}
+		// The first pass keeps labels inside the program, but an address past
+		// the last operand would silently spill into the opcode digit
+		if address < 0 || address > max {
+			return 0, s.errorAt(s.operandCol, "label '%s' resolves to %d, out of operand range", s.operand, address)
+		}
+		operand = address
+	} else if s.base > 0 && s.opcode != models.DAT {
+		// A linked file's numeric addresses count from where it was placed
+		if operand+s.base > max {
+			return 0, s.errorAt(s.operandCol, "operand %s is %d once the file is placed at %02d, exceeds maximum %d", s.operand, operand+s.base, s.base, max)
+		}
+		operand += s.base
+	} else if operand > max {
+		if written := strconv.Itoa(operand); written != s.operand {
+			return 0, s.errorAt(s.operandCol, "%s %s is %d, exceeds maximum %d", kind, s.operand, operand, max)
+		}
+		return 0, s.errorAt(s.operandCol, "%s %d exceeds maximum %d", kind, operand, max)
+	} else if operand < 0 {
+		return 0, s.errorAt(s.operandCol, "%s %d is below minimum 0", kind, operand)
+	}
+
+	return s.check(models.Instruction{Opcode: s.opcode, Operand: operand})
+}
+
+// labelSuggestions are names to offer instead of a label spelt like a
+// mnemonic
+var labelSuggestions = map[models.Opcode]string{
+	models.HLT: "done",
+	models.ADD: "sum",
+	models.SUB: "difference",
+	models.STA: "store",
+	models.LDA: "load",
+	models.BRA: "loop",
+	models.BRZ: "if_zero",
+	models.BRP: "if_positive",
+	models.INP: "input",
+	models.OUT: "output",
+	models.DAT: "data",
+}
+
+// reservedLabel explains that a label cannot be spelt like a mnemonic and
+// suggests another name
+func reservedLabel(dialect models.Dialect, name string) string {
+	opcode, _ := lookupOpcode(dialect, name)
+	suggestion, ok := labelSuggestions[opcode]
+	if !ok {
+		suggestion = strings.ToLower(name) + "_label"
+	}
+	return fmt.Sprintf("'%s' is a reserved mnemonic; rename the label (e.g. '%s')", name, suggestion)
+}
+
+// isReserved reports whether token is a mnemonic, an alias or a directive
+// such as EQU, any of which starts the instruction part of a line
+func isReserved(dialect models.Dialect, token string) bool {
+	_, ok := lookupOpcode(dialect, token)
+	return ok || models.Opcode(strings.ToUpper(token)) == equ
+}
+
+// lookupOpcode finds the opcode for a mnemonic in any case, including the
+// aliases and extensions of the dialect
+func lookupOpcode(dialect models.Dialect, mnemonic string) (models.Opcode, bool) {
+	mnemonic = strings.ToUpper(mnemonic)
+	if opcode, ok := dialect.Aliases[mnemonic]; ok {
+		return opcode, true
+	}
+
+	opcode := models.Opcode(mnemonic)
+	if _, ok := dialect.Extensions[opcode]; ok {
+		return opcode, true
+	}
+	if _, ok := models.Opcodes[opcode]; ok || opcode == models.DAT {
+		return opcode, true
+	}
+	return "", false
+}
+
+// isLabel reports whether the token can be used as a label name
+func isLabel(token string) bool {
+	for i, c := range token {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return token != ""
+}
+
+// parseNumber reads a number written in decimal or, after 0x, in hexadecimal
+func parseNumber(token string) (int, error) {
+	if len(token) > 2 && (token[:2] == "0x" || token[:2] == "0X") {
+		value, err := strconv.ParseUint(token[2:], 16, 32)
+		return int(value), err
+	}
+	return strconv.Atoi(token)
+}
+
+// isNumber reports whether token is written only with digits
+func isNumber(token string) bool {
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return token != ""
+}
+
+// stripComment drops everything after a ; or // comment marker
+func stripComment(text string) string {
+	if i := strings.Index(text, ";"); i >= 0 {
+		text = text[:i]
+	}
+	if i := strings.Index(text, "//"); i >= 0 {
+		text = text[:i]
+	}
+	return text
 }
@@ -0,0 +1,300 @@
+package compiler
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestScanLines(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"LF", "LDA x\nHLT\n", []string{"LDA x", "HLT"}},
+		{"CRLF", "LDA x\r\nHLT\r\n", []string{"LDA x", "HLT"}},
+		{"lone CR", "LDA x\rHLT\r", []string{"LDA x", "HLT"}},
+		{"mixed", "LDA x\r\nOUT\rHLT\nx DAT", []string{"LDA x", "OUT", "HLT", "x DAT"}},
+		{"blank CRLF lines", "\r\n\r\nHLT", []string{"", "", "HLT"}},
+	}
+
+	for _, test := range tests {
+		// Reading a byte at a time puts every CR at the end of the buffer
+		for _, r := range []io.Reader{strings.NewReader(test.src), iotest.OneByteReader(strings.NewReader(test.src))} {
+			scanner := bufio.NewScanner(r)
+			scanner.Split(scanLines)
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+			}
+		}
+	}
+}
+
+func TestScanLinesWaitsForByteAfterCR(t *testing.T) {
+	// A CR at the end of the data read so far may be the start of a CRLF
+	if advance, token, err := scanLines([]byte("HLT\r"), false); advance != 0 || token != nil || err != nil {
+		t.Errorf("got %d %q %v, want to wait for more data", advance, token, err)
+	}
+	if advance, token, _ := scanLines([]byte("HLT\r"), true); advance != 4 || string(token) != "HLT" {
+		t.Errorf("at EOF got %d %q, want 4 \"HLT\"", advance, token)
+	}
+}
+
+func TestLineNumbersMatchAcrossLineEndings(t *testing.T) {
+	for _, ending := range []string{"\n", "\r\n", "\r"} {
+		src := strings.Join([]string{"    LDA x", "    BRA nowhere", "x   DAT 0"}, ending)
+		_, err := AssembleString(src)
+		var asmErr *Error
+		if !errors.As(err, &asmErr) || asmErr.Line != 2 || asmErr.Text != "    BRA nowhere" {
+			t.Errorf("ending %q: got %v, want line 2", ending, err)
+		}
+	}
+}
+
+func TestTokenizeTabsAndSpaces(t *testing.T) {
+	tests := []struct {
+		line string
+		want []token
+	}{
+		{"LDA\t\tcount", []token{{"LDA", 1}, {"count", 6}}},
+		{"\tLDA count", []token{{"LDA", 2}, {"count", 6}}},
+		{"loop \t ADD\t one  ", []token{{"loop", 1}, {"ADD", 8}, {"one", 13}}},
+		{"\t \t", nil},
+	}
+
+	for _, test := range tests {
+		if got := tokenize(test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestAssembleTabSeparatedSource(t *testing.T) {
+	src := "\tLDA\t\tcount\nloop\tSUB one\n  \tBRP\t loop\n\tHLT\ncount\tDAT\t3\none DAT\t1"
+	ram, err := AssembleString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for address, want := range []int{504, 205, 801, 0, 3, 1} {
+		if int(ram[address]) != want {
+			t.Errorf("mailbox %d holds %03d, want %03d", address, ram[address], want)
+		}
+	}
+}
+
+func TestUnderlineWithTabs(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"\tLDA\tnowhere", "\tLDA\tnowhere\n\t   \t^~~~~~~"},
+		{"loop\t\tBRA  \tnowhere", "loop\t\tBRA  \tnowhere\n    \t\t     \t^~~~~~~"},
+		{"  \tLDA 5\t6", "  \tLDA 5\t6\n  \t     \t^"},
+	}
+
+	for _, test := range tests {
+		_, err := AssembleString(test.src)
+		var asmErr *Error
+		if !errors.As(err, &asmErr) {
+			t.Errorf("%q: got %v, want an *Error", test.src, err)
+			continue
+		}
+		if got := asmErr.Underline(); got != test.want {
+			t.Errorf("%q: underline\n%s\nwant\n%s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestSeveralLabelsOneMailbox(t *testing.T) {
+	src := "start\nmain\nentry   LDA x\n        BRA main\nx       DAT 7"
+	program, err := Assemble(strings.NewReader(src), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"start", "main", "entry"} {
+		if address, ok := program.Labels[name]; !ok || address != 0 {
+			t.Errorf("label %s is at %d (defined %v), want 0", name, address, ok)
+		}
+	}
+	if program.RAM[1] != 600 {
+		t.Errorf("BRA main assembled to %03d, want 600", program.RAM[1])
+	}
+}
+
+func TestDuplicateLabel(t *testing.T) {
+	tests := []string{
+		"start\nstart   LDA x\nx       DAT",
+		"start   LDA x\nstart   HLT\nx       DAT",
+	}
+	for _, src := range tests {
+		_, err := AssembleString(src)
+		var asmErr *Error
+		if !errors.As(err, &asmErr) || asmErr.Line != 2 || asmErr.Col != 1 || asmErr.Msg != "duplicate label 'start'" {
+			t.Errorf("%q: got %v, want duplicate label 'start' on line 2", src, err)
+		}
+	}
+}
+
+func TestStrictHalt(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "INP\nOUT\nHLT"},
+		{src: "INP\nOUT\nCOB"},
+		{src: "INP\nOUT\n000", err: "program has no HLT"},
+		{src: "INP\nOUT\n001\nHLT"},
+		{src: "INP\nOUT", err: "execution runs past the end of the program"},
+	}
+	for _, test := range tests {
+		_, err := Assemble(strings.NewReader(test.src), Options{Strict: true})
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%q: %v", test.src, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestRepeatedDAT(t *testing.T) {
+	tests := []struct {
+		src string
+		// want is the whole of memory after assembling
+		want  models.RAM
+		label int
+		err   string
+	}{
+		{src: "LDA table\nHLT\ntable DAT 7 * 3\nend DAT 1", want: models.RAM{0: 502, 1: 0, 2: 7, 3: 7, 4: 7, 5: 1}, label: 2},
+		{src: "LDA table\nHLT\ntable DAT 5 * 1", want: models.RAM{0: 502, 1: 0, 2: 5}, label: 2},
+		{src: "table DAT 7 * 0", err: "DAT repeat count '0' must be a number from 1 to 100"},
+		{src: "table DAT 7 * 101", err: "DAT repeat count '101' must be a number from 1 to 100"},
+		{src: "table DAT 7 * x", err: "DAT repeat count 'x' must be a number from 1 to 100"},
+		{src: "HLT\ntable DAT 7 * 100", err: "program does not fit in 100 mailboxes"},
+	}
+	for _, test := range tests {
+		program, err := Assemble(strings.NewReader(test.src), Options{})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if !program.RAM.Equal(test.want) {
+			t.Errorf("%q: got memory %v, want %v", test.src, program.RAM, test.want)
+		}
+		if address, ok := program.Labels["table"]; !ok || address != test.label {
+			t.Errorf("%q: table is at %d (defined %v), want %d", test.src, address, ok, test.label)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	for _, dialect := range []models.Dialect{models.Standard, models.Higginson} {
+		program, err := Assemble(strings.NewReader("IN\nOUT\nCOB"), Options{Dialect: dialect})
+		if err != nil {
+			t.Errorf("%s: %v", dialect.Name, err)
+			continue
+		}
+		if want := (models.RAM{0: 901, 1: 902, 2: 0}); !program.RAM.Equal(want) {
+			t.Errorf("%s: got memory %v, want %v", dialect.Name, program.RAM, want)
+		}
+	}
+}
+
+func TestEQU(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want models.RAM
+		err  string
+	}{
+		{name: "as an address", src: "SLOT EQU 3\nLDA SLOT\nHLT", want: models.RAM{0: 503, 1: 0}},
+		{name: "as a DAT value", src: "MAX EQU 999\nHLT\nDAT MAX", want: models.RAM{0: 0, 1: 999}},
+		{name: "used before it is defined", src: "LDA SLOT\nHLT\nSLOT EQU 7", want: models.RAM{0: 507, 1: 0}},
+		{name: "takes no mailbox", src: "A EQU 1\nB EQU 2\nHLT", want: models.RAM{0: 0}},
+		{name: "in any case", src: "SLOT equ 3\nLDA SLOT\nHLT", want: models.RAM{0: 503, 1: 0}},
+		{name: "no name", src: "EQU 5", err: "EQU needs a name, e.g. MAX EQU 99"},
+		{name: "no value", src: "MAX EQU", err: "EQU needs exactly one value"},
+		{name: "two values", src: "MAX EQU 5 6", err: "EQU needs exactly one value"},
+		{name: "value too big", src: "MAX EQU 1000", err: "EQU value 1000 exceeds maximum 999"},
+		{name: "value not a number", src: "MAX EQU five", err: "EQU value 'five' must be a number from 0 to 999"},
+		{name: "defined twice", src: "MAX EQU 5\nMAX EQU 6", err: "constant 'MAX' is already defined"},
+		{name: "already a label", src: "MAX HLT\nMAX EQU 6", err: "'MAX' is already a label"},
+		{name: "later used as a label", src: "MAX EQU 6\nMAX HLT", err: "'MAX' is already a constant"},
+		{name: "too big for an address", src: "BIG EQU 100\nLDA BIG", err: "constant 'BIG' is 100, exceeds maximum 99"},
+		{name: "local label", src: "1: EQU 5", err: "local label 1: cannot name a constant"},
+	}
+	for _, test := range tests {
+		program, err := Assemble(strings.NewReader(test.src), Options{})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !program.RAM.Equal(test.want) {
+			t.Errorf("%s: got memory %v, want %v", test.name, program.RAM, test.want)
+		}
+	}
+}
+
+func TestHexLiterals(t *testing.T) {
+	tests := []struct {
+		src  string
+		want models.RAM
+		err  string
+	}{
+		{src: "LDA 0x0A", want: models.RAM{0: 510}},
+		{src: "LDA 0x0a", want: models.RAM{0: 510}},
+		{src: "DAT 0X1f", want: models.RAM{0: 31}},
+		{src: "DAT 0x3E7", want: models.RAM{0: 999}},
+		{src: "N EQU 0x10\nLDA N", want: models.RAM{0: 516}},
+		{src: "DAT 0x3E8", err: "value 0x3E8 is 1000, exceeds maximum 999"},
+		{src: "LDA 0x64", err: "operand 0x64 is 100, exceeds maximum 99"},
+		{src: "N EQU 0x3E8", err: "EQU value 0x3E8 exceeds maximum 999"},
+		{src: "LDA 0xZZ", err: "'0xZZ' is not a hexadecimal number"},
+		{src: "LDA 0x", err: "'0x' is not a hexadecimal number"},
+	}
+	for _, test := range tests {
+		program, err := Assemble(strings.NewReader(test.src), Options{})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if !program.RAM.Equal(test.want) {
+			t.Errorf("%q: got memory %v, want %v", test.src, program.RAM, test.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package compiler
+
+import (
+	"strings"
+	"unicode"
+)
+
+// metadata is what the @key value directives in the comments at the top of a
+// program say about it, e.g. "; @title Fibonacci". It never changes the
+// assembled mailboxes
+type metadata struct {
+	title  string
+	author string
+}
+
+// parseDirective reads a directive from a comment line. Unknown keys and
+// comments that are not directives are ordinary comments and are ignored
+func (m *metadata) parseDirective(line string) {
+	text := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(text, ";"):
+		text = text[1:]
+	case strings.HasPrefix(text, "//"):
+		text = text[2:]
+	default:
+		return
+	}
+
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "@") {
+		return
+	}
+	key, value := text[1:], ""
+	if i := strings.IndexFunc(key, unicode.IsSpace); i >= 0 {
+		key, value = key[:i], strings.TrimSpace(key[i:])
+	}
+
+	switch strings.ToLower(key) {
+	case "title":
+		m.title = value
+	case "author":
+		m.author = value
+	}
+}
@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Line is one mailbox turned back into assembly
+type Line struct {
+	Address int
+	Value   models.Register
+	Label   string
+	Opcode  models.Opcode
+	// Operand is a label when the mailbox it refers to has one, otherwise a
+	// number. It is "" for instructions without an operand
+	Operand string
+}
+
+// Instruction is the line without its label, e.g. "LDA count"
+func (l Line) Instruction() string {
+	if l.Operand == "" {
+		return string(l.Opcode)
+	}
+	return fmt.Sprintf("%s %s", l.Opcode, l.Operand)
+}
+
+func (l Line) String() string {
+	return fmt.Sprintf("%-8s%s", l.Label, l.Instruction())
+}
+
+// Disassemble turns every mailbox held in ram back into assembly, in address
+// order. program supplies labels and which mailboxes are data; it may be nil
+func Disassemble(ram models.RAM, program *models.Program) []Line {
+	var lines []Line
+	ram.Each(func(address int, value models.Register) {
+		lines = append(lines, DisassembleMailbox(ram, address, program))
+	})
+	return lines
+}
+
+// DisassembleString turns ram back into source that AssembleString turns into
+// the same mailbox values. Unwritten mailboxes below the highest one in ram are
+// written as DAT 0 so every instruction keeps its address
+func DisassembleString(ram models.RAM) string {
+	addresses := ram.SortedAddresses()
+	if len(addresses) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for address := 0; address <= addresses[len(addresses)-1]; address++ {
+		line := Line{Address: address, Opcode: models.DAT, Operand: "0"}
+		if _, ok := ram[address]; ok {
+			line = DisassembleMailbox(ram, address, nil)
+		}
+		b.WriteString(line.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// DisassembleMailbox turns a single mailbox back into assembly. Values that are
+// not instructions, and mailboxes the program declared with DAT, come back as DAT
+func DisassembleMailbox(ram models.RAM, address int, program *models.Program) Line {
+	value := ram[address]
+	line := Line{Address: address, Value: value}
+
+	instruction := models.Decode(value)
+	if program != nil {
+		instruction = program.Dialect.Decode(value)
+		line.Label = program.Label(address)
+		if program.Source[address].Data {
+			instruction = models.Instruction{Opcode: models.DAT, Operand: int(value)}
+		}
+	}
+	// Only 000 is written HLT; the rest of 001-099 also halt but are more
+	// likely data
+	if instruction.Opcode == models.HLT && instruction.Operand != 0 {
+		instruction = models.Instruction{Opcode: models.DAT, Operand: int(value)}
+	}
+
+	line.Opcode = instruction.Opcode
+	switch {
+	case instruction.Opcode == models.DAT:
+		line.Operand = strconv.Itoa(instruction.Operand)
+	case instruction.Opcode.HasOperand():
+		line.Operand = strconv.Itoa(instruction.Operand)
+		if program != nil {
+			if label := program.Label(instruction.Operand); label != "" {
+				line.Operand = label
+			}
+		}
+	}
+	return line
+}
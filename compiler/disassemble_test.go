@@ -0,0 +1,61 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	// Adds two inputs and counts down, with data left after the HLT
+	src := `        INP
+        STA first
+        INP
+        ADD first
+        OUT
+loop    LDA count
+        BRZ done
+        SUB one
+        STA count
+        BRP loop
+done    HLT
+first   DAT
+count   DAT 3
+one     DAT 1`
+
+	ram, err := AssembleString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Data values that decode as HLT, as no instruction at all and as I/O,
+	// after a gap the disassembly has to fill
+	ram[20] = 5
+	ram[21] = 450
+	ram[22] = 901
+	ram[23] = 999
+	ram[30] = 0
+
+	again, err := AssembleString(DisassembleString(ram))
+	if err != nil {
+		t.Fatalf("%v in\n%s", err, DisassembleString(ram))
+	}
+	for address := 0; address < models.MaxSize; address++ {
+		if again[address] != ram[address] {
+			t.Errorf("mailbox %d holds %03d after the round trip, want %03d", address, again[address], ram[address])
+		}
+	}
+}
+
+func TestDisassembleRoundTripEveryValue(t *testing.T) {
+	for value := models.Register(0); value <= 999; value++ {
+		ram := models.RAM{0: value, 5: 1}
+		again, err := AssembleString(DisassembleString(ram))
+		if err != nil {
+			t.Errorf("%03d: %v", value, err)
+			continue
+		}
+		if again[0] != value || again[5] != 1 {
+			t.Errorf("%03d came back as %03d in\n%s", value, again[0], DisassembleString(ram))
+		}
+	}
+}
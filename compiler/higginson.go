@@ -0,0 +1,95 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// LoadHigginson reads a program saved from Peter Higginson's online LMC simulator
+// and loads it into RAM starting at mailbox 0.
+//
+// The format is assumed to be a list of mailbox values separated by spaces,
+// tabs or newlines. Every value is written as exactly three digits (000-999).
+// The list may be preceded by a count of the values that follow. The first
+// value is taken as the count whenever the number of values after it is the
+// number it holds, so a full memory saved as 100 then 100 values loads as
+// 100 mailboxes. A first value that is not three digits can only be a count,
+// and is an error when it does not match.
+func LoadHigginson(r io.Reader) (models.RAM, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	var tokens []string
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("higginson: no program found")
+	}
+
+	count, err := strconv.Atoi(tokens[0])
+	if err == nil && count == len(tokens)-1 {
+		tokens = tokens[1:]
+	} else if len(tokens[0]) != 3 {
+		// Values are always zero-padded, so this was meant as a count
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("higginson: value 1: %q is not a count or a three digit code", tokens[0])
+		}
+		return nil, fmt.Errorf("higginson: count says %d values but %d follow", count, len(tokens)-1)
+	}
+
+	if len(tokens) > models.MaxSize {
+		return nil, fmt.Errorf("higginson: %d values do not fit in %d mailboxes", len(tokens), models.MaxSize)
+	}
+
+	ram := make(models.RAM)
+	for i, token := range tokens {
+		value, err := parseCode(token)
+		if err != nil {
+			return nil, fmt.Errorf("higginson: mailbox %d: %v", i, err)
+		}
+		ram[i] = value
+	}
+
+	return ram, nil
+}
+
+// DumpHigginson writes RAM in the format accepted by the load box of Peter
+// Higginson's online LMC simulator, one three digit value per line.
+//
+// Every mailbox up to the highest one present in RAM is written, including
+// trailing DAT 0 cells, so the program comes back the same size when it is
+// loaded again with LoadHigginson.
+func DumpHigginson(ram models.RAM, w io.Writer) error {
+	last := -1
+	if addresses := ram.SortedAddresses(); len(addresses) > 0 {
+		last = addresses[len(addresses)-1]
+	}
+
+	buf := bufio.NewWriter(w)
+	for address := 0; address <= last; address++ {
+		fmt.Fprintf(buf, "%03d\n", ram[address])
+	}
+	return buf.Flush()
+}
+
+// parseCode parses a single zero-padded three digit mailbox value
+func parseCode(token string) (models.Register, error) {
+	if len(token) != 3 {
+		return 0, fmt.Errorf("%q is not a three digit code", token)
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%q is not a three digit code", token)
+		}
+	}
+	value, _ := strconv.Atoi(token)
+	return models.Register(value), nil
+}
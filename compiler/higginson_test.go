@@ -0,0 +1,83 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestLoadHigginson(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []models.Register
+		err  string
+	}{
+		{name: "values only", src: "901 902 000", want: []models.Register{901, 902, 0}},
+		{name: "values on lines", src: "901\n\t902\n000\n", want: []models.Register{901, 902, 0}},
+		{name: "with a count", src: "3 901 902 000", want: []models.Register{901, 902, 0}},
+		{name: "with a padded count", src: "003 901 902 000", want: []models.Register{901, 902, 0}},
+		{name: "a first value that is not the count", src: "002 901 902 000", want: []models.Register{2, 901, 902, 0}},
+		{name: "empty", src: " \n", err: "no program found"},
+		{name: "count does not match", src: "4 901 902 000", err: "count says 4 values but 3 follow"},
+		{name: "not a count", src: "x 901", err: `"x" is not a count or a three digit code`},
+		{name: "short value", src: "901 12 000", err: `mailbox 1: "12" is not a three digit code`},
+		{name: "not digits", src: "901 9a2", err: `mailbox 1: "9a2" is not a three digit code`},
+		{name: "too many values", src: strings.Repeat("000 ", 101), err: "101 values do not fit in 100 mailboxes"},
+	}
+
+	for _, test := range tests {
+		ram, err := LoadHigginson(strings.NewReader(test.src))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(ram) != len(test.want) {
+			t.Errorf("%s: loaded %d mailboxes, want %d", test.name, len(ram), len(test.want))
+			continue
+		}
+		for address, want := range test.want {
+			if ram[address] != want {
+				t.Errorf("%s: mailbox %02d holds %03d, want %03d", test.name, address, ram[address], want)
+			}
+		}
+	}
+}
+
+func TestHigginsonRoundTrip(t *testing.T) {
+	// A full memory, with trailing zero cells that must not be lost
+	ram := make(models.RAM)
+	for address := 0; address < models.MaxSize; address++ {
+		ram[address] = models.Register(address * 7 % 1000)
+	}
+	ram[models.MaxSize-1] = 0
+
+	var dumped bytes.Buffer
+	if err := DumpHigginson(ram, &dumped); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{dumped.String(), fmt.Sprintf("%d\n%s", models.MaxSize, dumped.String())} {
+		loaded, err := LoadHigginson(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loaded) != len(ram) {
+			t.Errorf("loaded %d mailboxes, want %d", len(loaded), len(ram))
+		}
+		for address, want := range ram {
+			if loaded[address] != want {
+				t.Errorf("mailbox %02d holds %03d, want %03d", address, loaded[address], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// include is the directive that splices another file into the source, e.g.
+// #include "lib.lmc"
+const include = "#include"
+
+// sourceLine is one line of source and where it came from
+type sourceLine struct {
+	// file is the included file the line is from, "" for the file being
+	// assembled
+	file string
+	line int
+	text string
+	// macro is the CALL or RET the line was expanded from, "" for a line
+	// written in the source
+	macro string
+	// unit is which of the files given to Link the line is from, 0 when
+	// assembling a single file
+	unit int
+}
+
+// readLines splits source into lines, remembering the file they are from
+func readLines(r io.Reader, file string) ([]sourceLine, error) {
+	var lines []sourceLine
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	for n := 1; scanner.Scan(); n++ {
+		lines = append(lines, sourceLine{file: file, line: n, text: scanner.Text()})
+	}
+	return lines, scanner.Err()
+}
+
+// rejectIncludes fails on the first #include line, for Options.NoIncludes
+func rejectIncludes(lines []sourceLine) error {
+	for _, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(stripComment(l.text)), include) {
+			return &Error{File: l.file, Line: l.line, Text: l.text, Msg: "#include is not allowed here"}
+		}
+	}
+	return nil
+}
+
+// expandIncludes replaces every #include line with the lines of the file it
+// names, read relative to dir, the directory of the file holding the
+// directive. visiting holds the files being expanded, to catch a file that
+// ends up including itself
+func expandIncludes(lines []sourceLine, dir string, visiting map[string]bool) ([]sourceLine, error) {
+	var expanded []sourceLine
+	for _, l := range lines {
+		text := strings.TrimSpace(stripComment(l.text))
+		if !strings.HasPrefix(text, include) {
+			expanded = append(expanded, l)
+			continue
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(text, include))
+		if len(name) < 3 || !strings.HasPrefix(name, `"`) || !strings.HasSuffix(name, `"`) {
+			return nil, &Error{File: l.file, Line: l.line, Msg: `#include needs a file name in quotes, e.g. #include "lib.lmc"`}
+		}
+		path := filepath.Join(dir, name[1:len(name)-1])
+
+		key, err := filepath.Abs(path)
+		if err != nil {
+			key = path
+		}
+		if visiting[key] {
+			return nil, &Error{File: l.file, Line: l.line, Msg: fmt.Sprintf("include cycle: %s is already being included", path)}
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, &Error{File: l.file, Line: l.line, Msg: fmt.Sprintf("#include: %v", err)}
+		}
+		included, err := readLines(f, path)
+		f.Close()
+		if err != nil {
+			return nil, &Error{File: l.file, Line: l.line, Msg: fmt.Sprintf("#include: %v", err)}
+		}
+
+		visiting[key] = true
+		included, err = expandIncludes(included, filepath.Dir(path), visiting)
+		delete(visiting, key)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, included...)
+	}
+	return expanded, nil
+}
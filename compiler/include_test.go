@@ -0,0 +1,110 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestInclude(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are written to a fresh directory, main.lmc is assembled
+		files map[string]string
+		opts  Options
+		want  models.RAM
+		err   string
+	}{
+		{
+			name: "spliced in where it is included",
+			files: map[string]string{
+				"main.lmc": "LDA x\n#include \"data.lmc\"\nHLT",
+				"data.lmc": "x DAT 5",
+			},
+			want: models.RAM{0: 501, 1: 5, 2: 0},
+		},
+		{
+			name: "paths are relative to the including file",
+			files: map[string]string{
+				"main.lmc":  "LDA x\nHLT\n#include \"lib/a.lmc\"",
+				"lib/a.lmc": "#include \"b.lmc\"",
+				"lib/b.lmc": "x DAT 5",
+			},
+			want: models.RAM{0: 502, 1: 0, 2: 5},
+		},
+		{
+			name: "the same file twice in a row is no cycle",
+			files: map[string]string{
+				"main.lmc": "HLT\n#include \"one.lmc\"\n#include \"one.lmc\"",
+				"one.lmc":  "DAT 1",
+			},
+			want: models.RAM{0: 0, 1: 1, 2: 1},
+		},
+		{
+			name: "a file including itself",
+			files: map[string]string{
+				"main.lmc": "HLT\n#include \"main.lmc\"",
+			},
+			err: "include cycle",
+		},
+		{
+			name: "files including each other",
+			files: map[string]string{
+				"main.lmc": "HLT\n#include \"a.lmc\"",
+				"a.lmc":    "#include \"b.lmc\"",
+				"b.lmc":    "#include \"a.lmc\"",
+			},
+			err: "include cycle",
+		},
+		{
+			name:  "a missing file",
+			files: map[string]string{"main.lmc": "#include \"gone.lmc\"\nHLT"},
+			err:   "#include: open",
+		},
+		{
+			name:  "no quotes",
+			files: map[string]string{"main.lmc": "#include lib.lmc\nHLT"},
+			err:   "#include needs a file name in quotes",
+		},
+		{
+			name: "not allowed",
+			files: map[string]string{
+				"main.lmc": "HLT\n#include \"one.lmc\"",
+				"one.lmc":  "DAT 1",
+			},
+			opts: Options{NoIncludes: true},
+			err:  "#include is not allowed here",
+		},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		for name, src := range test.files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		program, err := CompileFromFile(filepath.Join(dir, "main.lmc"), test.opts)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !program.RAM.Equal(test.want) {
+			t.Errorf("%s: got memory %v, want %v", test.name, program.RAM, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Mnemonic is an entry in the instruction set the assembler accepts
+type Mnemonic struct {
+	Opcode models.Opcode
+	// Code is the machine code it assembles to, e.g. 5xx where xx is the
+	// mailbox operand
+	Code string
+	// Aliases are other spellings the assembler accepts for it
+	Aliases []string
+}
+
+// Mnemonics lists the instruction set of the dialect in machine code order,
+// with DAT last
+func Mnemonics(dialect models.Dialect) []Mnemonic {
+	codes := make(map[models.Opcode]models.Register)
+	for opcode, code := range models.Opcodes {
+		codes[opcode] = code
+	}
+	for opcode, code := range dialect.Extensions {
+		codes[opcode] = code
+	}
+
+	var mnemonics []Mnemonic
+	for opcode, code := range codes {
+		m := Mnemonic{Opcode: opcode, Code: fmt.Sprintf("%03d", code)}
+		if opcode.HasOperand() {
+			m.Code = fmt.Sprintf("%dxx", code/100)
+		}
+		mnemonics = append(mnemonics, m)
+	}
+	sort.Slice(mnemonics, func(i, j int) bool {
+		return codes[mnemonics[i].Opcode] < codes[mnemonics[j].Opcode]
+	})
+	mnemonics = append(mnemonics, Mnemonic{Opcode: models.DAT, Code: "any"})
+
+	for i := range mnemonics {
+		for alias, opcode := range dialect.Aliases {
+			if opcode == mnemonics[i].Opcode {
+				mnemonics[i].Aliases = append(mnemonics[i].Aliases, alias)
+			}
+		}
+		sort.Strings(mnemonics[i].Aliases)
+	}
+	return mnemonics
+}
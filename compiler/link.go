@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Link assembles several source files into one program, each placed in
+// memory straight after the one before, such as a main program followed by a
+// library of subroutines. Labels and constants are shared, so one file can
+// branch to, CALL or load from a label in another, and every operand is
+// resolved against where its target ended up. A numeric address such as
+// LDA 3 counts from the start of its own file and is moved along with it,
+// and local labels such as 1f only reach definitions in the same file. Raw
+// machine code and DAT values are left as written. A name defined in more than
+// one file is an error, as is a combined program that does not fit in
+// memory. Mailboxes record which file they came from in Source, and the
+// title and author come from the first file
+func Link(paths []string, opts Options) (*models.Program, error) {
+	dialect := opts.Dialect
+	if dialect.Name == "" {
+		dialect = models.Standard
+	}
+
+	var all []sourceLine
+	var meta metadata
+	definedIn := make(map[string]string)
+	for i, path := range paths {
+		lines, err := readSource(path, path, opts)
+		if err != nil {
+			return nil, err
+		}
+		for n := range lines {
+			lines[n].unit = i
+		}
+
+		defined, header, err := definitions(lines, path, dialect)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			meta = header
+		}
+		for _, s := range defined {
+			if other, ok := definedIn[s.label]; ok {
+				return nil, s.errorAt(s.labelCol, "'%s' is already defined in %s", s.label, other)
+			}
+			definedIn[s.label] = path
+		}
+
+		all = append(all, lines...)
+	}
+
+	// CALL and RET are expanded once for the whole program, so a call in
+	// one file can reach a subroutine in another
+	program, err := assemble(all, opts)
+	if err != nil {
+		return nil, err
+	}
+	program.Title, program.Author = meta.title, meta.author
+	return program, nil
+}
+
+// definitions finds the labels and constants the lines of one file define,
+// without expanding or checking CALL and RET, which may use names from other
+// files. Local labels such as 1: are left out, since they can be defined any
+// number of times. metadata holds the directives in the comments before the
+// first line of code in file itself
+func definitions(lines []sourceLine, file string, dialect models.Dialect) ([]statement, metadata, error) {
+	var defined []statement
+	var meta metadata
+	header := true
+	for _, l := range lines {
+		if label, opcode, _ := macroLine(l); opcode != "" {
+			header = false
+			if label != "" && !isLocalLabel(label) {
+				tokens := tokenize(stripComment(l.text))
+				defined = append(defined, statement{file: l.file, line: l.line, raw: l.text, label: label, labelCol: tokens[0].col})
+			}
+			continue
+		}
+
+		s, ok, err := parseLine(l, dialect)
+		if err != nil {
+			return nil, meta, err
+		}
+		if !ok {
+			if header && l.file == file {
+				meta.parseDirective(l.text)
+			}
+			continue
+		}
+		header = false
+		if s.label != "" && !isLocalLabel(s.label) {
+			defined = append(defined, s)
+		}
+	}
+	return defined, meta, nil
+}
@@ -0,0 +1,105 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		first string
+		// second is linked straight after first
+		second string
+		// want is every mailbox the second file's code starts at and after,
+		// nil when only the error matters
+		want map[int]models.Register
+		err  string
+	}{
+		{
+			name:   "numeric addresses move with their file",
+			first:  "HLT",
+			second: "LDA 3\nBRA 0\nHLT\nDAT 5",
+			want:   map[int]models.Register{1: 504, 2: 601, 3: 0, 4: 5},
+		},
+		{
+			name:   "machine code and DAT values stay as written",
+			first:  "HLT",
+			second: "503\nDAT 3",
+			want:   map[int]models.Register{1: 503, 2: 3},
+		},
+		{
+			name:   "a label in the other file",
+			first:  "LDA x\nHLT",
+			second: "x DAT 7",
+			want:   map[int]models.Register{2: 7},
+		},
+		{
+			name:   "local labels resolve inside their file",
+			first:  "1: HLT",
+			second: "BRA 1f\n1: HLT\nBRA 1b",
+			want:   map[int]models.Register{1: 602, 2: 0, 3: 602},
+		},
+		{
+			name:   "local labels do not reach the next file",
+			first:  "BRA 1f\nHLT",
+			second: "1: HLT",
+			err:    "no local label 1: after '1f'",
+		},
+		{
+			name:   "local labels do not reach the file before",
+			first:  "1: HLT",
+			second: "BRA 1b",
+			err:    "no local label 1: at or before '1b'",
+		},
+		{
+			name:   "CALL into the other file",
+			first:  "INP\nCALL double\nOUT\nHLT",
+			second: "double STA n\nADD n\nRET\nn DAT",
+		},
+		{
+			name:   "a name in both files",
+			first:  "x DAT",
+			second: "x DAT",
+			err:    "'x' is already defined in",
+		},
+		{
+			name:   "relocated past the last mailbox",
+			first:  "DAT 0 * 60",
+			second: "LDA 50",
+			err:    "operand 50 is 110 once the file is placed at 60, exceeds maximum 99",
+		},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		first, second := filepath.Join(dir, "first.lmc"), filepath.Join(dir, "second.lmc")
+		if err := os.WriteFile(first, []byte(test.first), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(second, []byte(test.second), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		program, err := Link([]string{first, second}, Options{})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for address, want := range test.want {
+			if got := program.RAM[address]; got != want {
+				t.Errorf("%s: mailbox %02d holds %03d, want %03d", test.name, address, got, want)
+			}
+		}
+	}
+}
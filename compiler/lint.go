@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Warning is a likely mistake in a program that still assembles
+type Warning struct {
+	// File is the included file the warning is about, "" for the file being
+	// assembled
+	File string
+	Line int
+	Msg  string
+}
+
+func (w Warning) String() string {
+	switch {
+	case w.Line == 0:
+		return w.Msg
+	case w.File != "":
+		return fmt.Sprintf("%s line %d: %s", w.File, w.Line, w.Msg)
+	}
+	return fmt.Sprintf("line %d: %s", w.Line, w.Msg)
+}
+
+// Lint looks through an assembled program for code that will probably not do
+// what the author meant. Warnings come back in address order
+func Lint(program *models.Program) []Warning {
+	var warnings []Warning
+	// Mailboxes above the last one assembled are blank memory
+	last := -1
+	if addresses := program.RAM.SortedAddresses(); len(addresses) > 0 {
+		last = addresses[len(addresses)-1]
+	}
+
+	for address := 0; address < program.Size; address++ {
+		source, ok := program.Source[address]
+		if !ok || source.Data {
+			continue
+		}
+
+		instruction := program.Dialect.Decode(program.RAM[address])
+		switch instruction.Opcode {
+		case models.BRA, models.BRZ, models.BRP:
+			if instruction.Operand > last {
+				warnings = append(warnings, Warning{
+					File: source.File,
+					Line: source.Line,
+					Msg:  fmt.Sprintf("branch to unassembled memory at address %d", instruction.Operand),
+				})
+			}
+			if target, ok := program.Source[instruction.Operand]; ok && target.Data {
+				warnings = append(warnings, Warning{
+					File: source.File,
+					Line: source.Line,
+					Msg:  fmt.Sprintf("branch to data cell %s", mailboxName(program, instruction.Operand)),
+				})
+			}
+		case models.STA:
+			// Storing over code is either deliberate self-modification or
+			// a clobbered instruction. CALL stores its return branch on purpose
+			if target, ok := program.Source[instruction.Operand]; ok && !target.Data && !source.Macro {
+				msg := fmt.Sprintf("STA to code cell at address %d", instruction.Operand)
+				if label := program.Label(instruction.Operand); label != "" {
+					msg += fmt.Sprintf(" (label '%s')", label)
+				}
+				warnings = append(warnings, Warning{File: source.File, Line: source.Line, Msg: msg})
+			}
+		}
+	}
+
+	return warnings
+}
+
+// mailboxName names a mailbox by its label when it has one
+func mailboxName(program *models.Program, address int) string {
+	name := program.Label(address)
+	if name == "" {
+		return fmt.Sprintf("at address %d", address)
+	}
+	return fmt.Sprintf("'%s'", name)
+}
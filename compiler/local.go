@@ -0,0 +1,60 @@
+package compiler
+
+import "strings"
+
+// Local labels are numbers that can be defined any number of times, written
+// 1: where they are defined. An operand of 1b refers to the nearest 1: at or
+// before its own line and 1f to the nearest after it, so short loops need no
+// names:
+//
+//	1:      LDA count
+//	        SUB one
+//	        STA count
+//	        BRP 1b
+
+// localLabel is one definition of a local label
+type localLabel struct {
+	// seq is the position of the defining line in the source, to tell which
+	// definitions come before a reference and which after
+	seq int
+	// unit is the linked file the definition is in. References never reach
+	// into another file
+	unit    int
+	address int
+}
+
+// isLocalLabel reports whether token defines a local label, such as 1:
+func isLocalLabel(token string) bool {
+	return strings.HasSuffix(token, ":") && isNumber(strings.TrimSuffix(token, ":"))
+}
+
+// isLocalRef reports whether token refers to a local label, such as 1b or 1f
+func isLocalRef(token string) bool {
+	if len(token) < 2 {
+		return false
+	}
+	suffix := strings.ToLower(token[len(token)-1:])
+	return (suffix == "b" || suffix == "f") && isNumber(token[:len(token)-1])
+}
+
+// resolveLocal finds the address a local label reference from the statement
+// points to
+func resolveLocal(s statement, syms symbols) (int, error) {
+	name := s.operand[:len(s.operand)-1]
+	definitions := syms.locals[name]
+	if strings.ToLower(s.operand[len(s.operand)-1:]) == "b" {
+		for i := len(definitions) - 1; i >= 0; i-- {
+			if definitions[i].seq <= s.seq && definitions[i].unit == s.unit {
+				return definitions[i].address, nil
+			}
+		}
+		return 0, s.errorAt(s.operandCol, "no local label %s: at or before '%s'", name, s.operand)
+	}
+
+	for _, definition := range definitions {
+		if definition.seq > s.seq && definition.unit == s.unit {
+			return definition.address, nil
+		}
+	}
+	return 0, s.errorAt(s.operandCol, "no local label %s: after '%s'", name, s.operand)
+}
@@ -0,0 +1,107 @@
+package compiler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestLocalLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// want maps mailboxes to the values they must hold
+		want models.RAM
+	}{
+		{
+			name: "reused label resolves to the nearest definition",
+			src: `1:  LDA x
+    BRZ 1f
+    BRA 1b
+1:  LDA x
+    BRP 1b
+    HLT
+x   DAT 0`,
+			want: models.RAM{1: 703, 2: 600, 4: 803},
+		},
+		{
+			name: "backward reference on the defining line is the line itself",
+			src: `    LDA x
+1:  BRA 1b
+x   DAT 0`,
+			want: models.RAM{1: 601},
+		},
+		{
+			name: "forward reference skips the defining line",
+			src: `1:  BRA 1f
+1:  HLT`,
+			want: models.RAM{0: 601},
+		},
+		{
+			name: "label on a line of its own names the next instruction",
+			src: `    LDA x
+2:
+    SUB x
+    BRP 2b
+    HLT
+x   DAT 5`,
+			want: models.RAM{2: 801},
+		},
+	}
+
+	for _, test := range tests {
+		ram, err := AssembleString(test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for address, value := range test.want {
+			if ram[address] != value {
+				t.Errorf("%s: mailbox %d holds %03d, want %03d", test.name, address, ram[address], value)
+			}
+		}
+	}
+}
+
+func TestLocalLabelErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+		col  int
+		msg  string
+	}{
+		{
+			name: "forward reference with no later definition",
+			src:  "1:  LDA x\n    BRA 1f\nx   DAT 0",
+			line: 2, col: 9,
+			msg: "no local label 1: after '1f'",
+		},
+		{
+			name: "backward reference with no earlier definition",
+			src:  "    BRA 1b\n1:  HLT",
+			line: 1, col: 9,
+			msg: "no local label 1: at or before '1b'",
+		},
+		{
+			name: "local label naming a constant",
+			src:  "1: EQU 5\n    HLT",
+			line: 1, col: 1,
+			msg: "local label 1: cannot name a constant",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := AssembleString(test.src)
+		var asmErr *Error
+		if !errors.As(err, &asmErr) {
+			t.Errorf("%s: got %v, want an *Error", test.name, err)
+			continue
+		}
+		if asmErr.Line != test.line || asmErr.Col != test.col || !strings.Contains(asmErr.Msg, test.msg) {
+			t.Errorf("%s: got line %d col %d %q, want line %d col %d %q", test.name, asmErr.Line, asmErr.Col, asmErr.Msg, test.line, test.col, test.msg)
+		}
+	}
+}
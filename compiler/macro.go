@@ -0,0 +1,129 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The subroutine macros. LMC has no call stack, so CALL stores a branch back
+// to the instruction after it in the subroutine's return cell, which RET
+// marks, and then branches to the subroutine. Each subroutine has a single
+// return cell, so a subroutine cannot call itself or be re-entered before it
+// returns
+const (
+	call = "CALL"
+	ret  = "RET"
+)
+
+// expandMacros rewrites CALL and RET into plain instructions before addresses
+// are assigned, so the labels they generate resolve like any other. The
+// accumulator is saved across the call so it can carry an argument in. The
+// data the calls need goes at the end of the program
+func expandMacros(lines []sourceLine) ([]sourceLine, error) {
+	// A subroutine is a label some CALL branches to. The first call to each
+	// is kept to report a subroutine with no RET
+	subroutines := make(map[string]sourceLine)
+	var called []string
+	for _, l := range lines {
+		if _, opcode, operand := macroLine(l); opcode == call {
+			if _, ok := subroutines[operand]; !ok {
+				subroutines[operand] = l
+				called = append(called, operand)
+			}
+		}
+	}
+
+	var expanded, data []sourceLine
+	returns := make(map[string]bool)
+	current := ""
+	calls := 0
+	for _, l := range lines {
+		label, opcode, operand := macroLine(l)
+		if _, ok := subroutines[label]; ok && label != "" {
+			current = label
+		}
+		if opcode == "" {
+			expanded = append(expanded, l)
+			continue
+		}
+
+		emit := func(text string) {
+			expanded = append(expanded, sourceLine{file: l.file, line: l.line, text: text, macro: opcode, unit: l.unit})
+		}
+		if label != "" {
+			emit(label)
+		}
+
+		switch opcode {
+		case call:
+			if !isLabel(operand) {
+				return nil, &Error{File: l.file, Line: l.line, Text: l.text, Msg: "CALL needs the label of a subroutine, e.g. CALL multiply"}
+			}
+			calls++
+			back := fmt.Sprintf("__call%d", calls)
+			emit("        STA __acc")
+			emit(fmt.Sprintf("        LDA %s_at", back))
+			emit("        ADD __bra")
+			emit(fmt.Sprintf("        STA %s__ret", operand))
+			emit("        LDA __acc")
+			emit(fmt.Sprintf("        BRA %s", operand))
+			// The label takes the next instruction, where the call returns to
+			emit(back)
+			data = append(data, sourceLine{file: l.file, line: l.line, text: fmt.Sprintf("%s_at DAT %s", back, back), macro: opcode, unit: l.unit})
+		case ret:
+			if current == "" {
+				return nil, &Error{File: l.file, Line: l.line, Text: l.text, Msg: "RET is not inside a subroutine, no CALL branches to a label before it"}
+			}
+			if returns[current] {
+				emit(fmt.Sprintf("        BRA %s__ret", current))
+				continue
+			}
+			returns[current] = true
+			// CALL overwrites this with a branch back to the caller
+			emit(fmt.Sprintf("%s__ret HLT", current))
+		}
+	}
+
+	for _, name := range called {
+		if !returns[name] {
+			l := subroutines[name]
+			return nil, &Error{File: l.file, Line: l.line, Text: l.text, Msg: fmt.Sprintf("subroutine '%s' has no RET", name)}
+		}
+	}
+	if calls == 0 {
+		return expanded, nil
+	}
+
+	data = append([]sourceLine{
+		{text: "__acc DAT", macro: call},
+		{text: "__bra DAT 600", macro: call},
+	}, data...)
+	return append(expanded, data...), nil
+}
+
+// macroLine picks the label, macro and operand out of a line. opcode is ""
+// when the line is not a macro
+func macroLine(l sourceLine) (label, opcode, operand string) {
+	tokens := tokenize(stripComment(l.text))
+	if len(tokens) > 0 && !isMacro(tokens[0].text) {
+		label, tokens = tokens[0].text, tokens[1:]
+	}
+	if len(tokens) == 0 || !isMacro(tokens[0].text) {
+		return label, "", ""
+	}
+
+	opcode = strings.ToUpper(tokens[0].text)
+	if len(tokens) > 1 {
+		operand = tokens[1].text
+	}
+	return label, opcode, operand
+}
+
+// isMacro reports whether a token is CALL or RET
+func isMacro(token string) bool {
+	switch strings.ToUpper(token) {
+	case call, ret:
+		return true
+	}
+	return false
+}
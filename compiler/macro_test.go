@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestExpandMacros(t *testing.T) {
+	lines, err := readLines(strings.NewReader("main CALL sub\nHLT\nsub OUT\nRET"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := expandMacros(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, l := range expanded {
+		got = append(got, strings.TrimSpace(l.text))
+	}
+	want := []string{
+		"main",
+		"STA __acc",
+		"LDA __call1_at",
+		"ADD __bra",
+		"STA sub__ret",
+		"LDA __acc",
+		"BRA sub",
+		"__call1",
+		"HLT",
+		"sub OUT",
+		"sub__ret HLT",
+		"__acc DAT",
+		"__bra DAT 600",
+		"__call1_at DAT __call1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCallTwice(t *testing.T) {
+	src := "INP\nCALL double\nOUT\nCALL double\nOUT\nHLT\ndouble STA n\nADD n\nRET\nn DAT"
+	program, err := Assemble(strings.NewReader(src), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ram, labels := program.RAM, program.Labels
+
+	// Each call stores its own way back in the one return cell
+	ret := models.Register(300 + labels["double__ret"])
+	for _, call := range []struct {
+		name string
+		// at is the mailbox of the call's STA into the return cell
+		at   int
+		back int
+	}{
+		{"__call1", 4, 7},
+		{"__call2", 11, 14},
+	} {
+		if labels[call.name] != call.back {
+			t.Errorf("%s returns to %d, want %d", call.name, labels[call.name], call.back)
+		}
+		if got := ram[labels[call.name+"_at"]]; got != models.Register(call.back) {
+			t.Errorf("%s_at holds %03d, want %03d", call.name, got, call.back)
+		}
+		if ram[call.at] != ret {
+			t.Errorf("%s stores with %03d, want %03d", call.name, ram[call.at], ret)
+		}
+		if branch := ram[call.at+2]; branch != models.Register(600+labels["double"]) {
+			t.Errorf("%s branches with %03d, want BRA double", call.name, branch)
+		}
+	}
+	if got := ram[labels["__bra"]]; got != 600 {
+		t.Errorf("__bra holds %03d, want 600", got)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "CALL sub\nHLT\nsub OUT", err: "subroutine 'sub' has no RET"},
+		{src: "HLT\nRET", err: "RET is not inside a subroutine"},
+		{src: "CALL\nHLT", err: "CALL needs the label of a subroutine"},
+		{src: "CALL 5\nHLT", err: "CALL needs the label of a subroutine"},
+	}
+	for _, test := range tests {
+		_, err := Assemble(strings.NewReader(test.src), Options{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
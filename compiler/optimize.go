@@ -0,0 +1,59 @@
+package compiler
+
+import (
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Optimize removes DAT cells from the end of the program when no instruction
+// or DAT refers to them, and returns how many it removed. Only cells after the
+// last one still in use go, so no other mailbox moves and no code changes.
+//
+// A DAT holding anything but 0 is kept, since code can reach it through an
+// address it computes or writes into itself. So is every cell of a repeated
+// DAT such as DAT 0 * 20 once any of them is referenced, as the code indexes
+// the rest from the first
+func Optimize(program *models.Program) int {
+	referenced := make(map[int]bool)
+	for address, source := range program.Source {
+		if !source.Data {
+			if instruction := models.Decode(program.RAM[address]); instruction.Opcode.HasOperand() {
+				referenced[instruction.Operand] = true
+			}
+		}
+		if address, ok := program.Labels[source.Operand]; ok {
+			referenced[address] = true
+		}
+	}
+
+	// The cells of a repeated DAT all come from the same source line
+	type origin struct {
+		file string
+		line int
+	}
+	usedBlocks := make(map[origin]bool)
+	for address := range referenced {
+		if source, ok := program.Source[address]; ok && source.Data {
+			usedBlocks[origin{source.File, source.Line}] = true
+		}
+	}
+
+	removed := 0
+	addresses := program.RAM.SortedAddresses()
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := addresses[i]
+		source := program.Source[address]
+		if !source.Data || referenced[address] || program.RAM[address] != 0 || usedBlocks[origin{source.File, source.Line}] {
+			break
+		}
+
+		delete(program.RAM, address)
+		delete(program.Source, address)
+		for label, labelAddress := range program.Labels {
+			if labelAddress == address {
+				delete(program.Labels, label)
+			}
+		}
+		removed++
+	}
+	return removed
+}
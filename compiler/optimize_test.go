@@ -0,0 +1,61 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		removed int
+		// left is how many mailboxes the program has afterwards
+		left int
+	}{
+		{
+			name:    "unused zero cells at the end go",
+			src:     "LDA x\nHLT\nx DAT 0\ny DAT\nz DAT 0",
+			removed: 2,
+			left:    3,
+		},
+		{
+			name:    "a used array keeps every cell",
+			src:     "LDA table\nHLT\ntable DAT 0 * 3",
+			removed: 0,
+			left:    5,
+		},
+		{
+			name:    "an unused array goes with the rest",
+			src:     "LDA x\nHLT\nx DAT\ntable DAT 0 * 3",
+			removed: 3,
+			left:    3,
+		},
+		{
+			name:    "a nonzero value is kept",
+			src:     "LDA x\nHLT\nx DAT\ntable DAT 7 * 3\nspare DAT",
+			removed: 1,
+			left:    6,
+		},
+		{
+			name:    "a cell pointed to by a DAT is kept",
+			src:     "LDA p\nHLT\np DAT x\nx DAT",
+			removed: 0,
+			left:    4,
+		},
+	}
+
+	for _, test := range tests {
+		program, err := Assemble(strings.NewReader(test.src), Options{})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if removed := Optimize(program); removed != test.removed {
+			t.Errorf("%s: removed %d cells, want %d", test.name, removed, test.removed)
+		}
+		if left := len(program.RAM.SortedAddresses()); left != test.left {
+			t.Errorf("%s: %d mailboxes left, want %d", test.name, left, test.left)
+		}
+	}
+}
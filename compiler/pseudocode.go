@@ -0,0 +1,68 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Pseudocode renders the program as high-level statements, one per mailbox:
+// loads and stores become assignments to variables named after labels, and
+// branches become gotos. Mailboxes without a label are written mem[NN]
+func Pseudocode(program *models.Program) string {
+	var b strings.Builder
+	program.RAM.Each(func(address int, value models.Register) {
+		line := DisassembleMailbox(program.RAM, address, program)
+		if line.Opcode == models.DAT {
+			fmt.Fprintf(&b, "var %s = %s\n", variable(program, address), line.Operand)
+			return
+		}
+		if line.Label != "" {
+			fmt.Fprintf(&b, "%s:\n", line.Label)
+		}
+		fmt.Fprintf(&b, "    %s\n", pseudoStatement(program, program.Dialect.Decode(line.Value)))
+	})
+	return b.String()
+}
+
+// pseudoStatement is the statement for one instruction
+func pseudoStatement(program *models.Program, instruction models.Instruction) string {
+	operand := variable(program, instruction.Operand)
+	target := program.Label(instruction.Operand)
+	if target == "" {
+		target = fmt.Sprintf("%02d", instruction.Operand)
+	}
+
+	switch instruction.Opcode {
+	case models.ADD:
+		return fmt.Sprintf("acc = acc + %s", operand)
+	case models.SUB:
+		return fmt.Sprintf("acc = acc - %s", operand)
+	case models.STA:
+		return fmt.Sprintf("%s = acc", operand)
+	case models.LDA:
+		return fmt.Sprintf("acc = %s", operand)
+	case models.BRA:
+		return fmt.Sprintf("goto %s", target)
+	case models.BRZ:
+		return fmt.Sprintf("if acc == 0 goto %s", target)
+	case models.BRP:
+		return fmt.Sprintf("if acc >= 0 goto %s", target)
+	case models.INP:
+		return "acc = input"
+	case models.OUT:
+		return "output acc"
+	case models.OTC:
+		return "output char(acc)"
+	}
+	return "halt"
+}
+
+// variable names a mailbox used as data: its label, or mem[NN] without one
+func variable(program *models.Program, address int) string {
+	if label := program.Label(address); label != "" {
+		return label
+	}
+	return fmt.Sprintf("mem[%02d]", address)
+}
@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// SourceMapVersion is the version of the source map schema. It goes up when a
+// change would break a tool reading an older map
+const SourceMapVersion = 1
+
+// SourceMap ties every assembled mailbox to the source that produced it, so a
+// debugger can show the line that is about to run
+type SourceMap struct {
+	Version int `json:"version"`
+	// Mailboxes is keyed by mailbox address. Mailboxes the program did not
+	// assemble are left out
+	Mailboxes map[int]SourceMapEntry `json:"mailboxes"`
+}
+
+// SourceMapEntry is where in the source a mailbox came from
+type SourceMapEntry struct {
+	// File is the included file the line is in, left out for the file being
+	// assembled
+	File string `json:"file,omitempty"`
+	Line int    `json:"line"`
+	// Col is the column the instruction starts in, from 1
+	Col int `json:"col"`
+	// Text is the line as written, without leading and trailing space. For
+	// CALL and RET it is the instruction the macro expanded into
+	Text string `json:"text"`
+}
+
+// NewSourceMap builds the source map of an assembled program
+func NewSourceMap(program *models.Program) SourceMap {
+	m := SourceMap{Version: SourceMapVersion, Mailboxes: make(map[int]SourceMapEntry)}
+	for address, source := range program.Source {
+		m.Mailboxes[address] = SourceMapEntry{File: source.File, Line: source.Line, Col: source.Col, Text: source.Text}
+	}
+	return m
+}
@@ -0,0 +1,66 @@
+package compiler
+
+import "sort"
+
+// maxSuggestDistance is the most edits a name may be from a label for the
+// label to be offered as what was meant. Further than that the guess is more
+// likely to mislead than help
+const maxSuggestDistance = 2
+
+// closestName finds the name among the symbols that name was most likely a
+// typo of, reporting false when none is close. Names shorter than the edits it
+// would take are never suggested, so x does not become y. Ties go to the
+// alphabetically first name
+func closestName(name string, syms symbols) (string, bool) {
+	var names []string
+	for label := range syms.labels {
+		names = append(names, label)
+	}
+	for constant := range syms.constants {
+		names = append(names, constant)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", maxSuggestDistance+1
+	for _, candidate := range names {
+		if d := editDistance(name, candidate); d < bestDistance && d < len(name) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance is the Levenshtein distance between a and b: the fewest
+// insertions, deletions and substitutions of a byte that turn one into the
+// other
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// min3 is the smallest of three numbers
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,49 @@
+package compiler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"loop", "loop", 0},
+		{"", "abc", 3},
+		{"lop", "loop", 1},
+		{"loop", "lop", 1},
+		{"cuont", "count", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestUndefinedLabelSuggestion(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"one letter off", "LDA cont\nHLT\ncount DAT", "undefined label 'cont' (did you mean 'count'?)"},
+		{"two letters swapped", "BRA lopo\nloop HLT", "undefined label 'lopo' (did you mean 'loop'?)"},
+		{"a constant", "LDA MAXX\nHLT\nMAX EQU 9", "undefined label 'MAXX' (did you mean 'MAX'?)"},
+		{"ties go to the first name", "LDA ab\nHLT\nac DAT\naa DAT", "undefined label 'ab' (did you mean 'aa'?)"},
+		{"nothing close", "LDA total\nHLT\ncount DAT", "undefined label 'total'"},
+		{"too short to guess", "LDA x\nHLT\ny DAT", "undefined label 'x'"},
+	}
+	for _, test := range tests {
+		_, err := Assemble(strings.NewReader(test.src), Options{})
+		var asmErr *Error
+		if err == nil || !errors.As(err, &asmErr) || asmErr.Msg != test.want {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
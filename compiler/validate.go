@@ -0,0 +1,97 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// LoadMachineCode reads a compiled program of mailbox values into RAM from
+// mailbox 0, checked as ValidateMachineCode does but ignoring its warnings
+func LoadMachineCode(r io.Reader, size int) (models.RAM, error) {
+	ram, _, err := ValidateMachineCode(r, size)
+	return ram, err
+}
+
+// ValidateMachineCode checks a compiled program, mailbox values separated by
+// spaces, tabs or newlines, without assembling or running it. Every value must
+// be a whole number from 0 to 999 and they must fit in size mailboxes; the
+// first that does not is the error, pointing at its line and column. The
+// values are loaded into RAM from mailbox 0.
+//
+// A program with no HLT, or with values such as 4xx that are no instruction,
+// still loads but is reported in the warnings, since a data cell can hold
+// either on purpose
+func ValidateMachineCode(r io.Reader, size int) (models.RAM, []Warning, error) {
+	if size == 0 {
+		size = models.MaxSize
+	}
+	if size < 1 || size > models.MaxSize {
+		return nil, nil, fmt.Errorf("memory size %d must be from 1 to %d mailboxes", size, models.MaxSize)
+	}
+
+	ram := make(models.RAM)
+	lines := make(map[int]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	for n := 1; scanner.Scan(); n++ {
+		for _, field := range tokenize(stripComment(scanner.Text())) {
+			value, err := machineValue(field.text)
+			if err != nil {
+				return nil, nil, &Error{Line: n, Col: field.col, Text: scanner.Text(), Msg: err.Error()}
+			}
+			address := len(lines)
+			if address >= size {
+				return nil, nil, &Error{Line: n, Col: field.col, Text: scanner.Text(), Msg: fmt.Sprintf("value %d does not fit in %d mailboxes", address+1, size)}
+			}
+			ram[address] = value
+			lines[address] = n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil, &Error{Msg: "no values found"}
+	}
+
+	var warnings []Warning
+	halts := false
+	for address := 0; address < len(lines); address++ {
+		value := ram[address]
+		instruction := models.Decode(value)
+		switch {
+		case instruction.Opcode == models.HLT:
+			halts = true
+		case instruction.Opcode == models.DAT:
+			warnings = append(warnings, Warning{Line: lines[address], Msg: fmt.Sprintf("%03d in mailbox %d is not an instruction", value, address)})
+		}
+	}
+	if !halts {
+		warnings = append(warnings, Warning{Msg: "program has no HLT"})
+	}
+
+	return ram, warnings, nil
+}
+
+// machineValue parses one value of a machine code file, saying what is wrong
+// with it when it cannot go in a mailbox
+func machineValue(token string) (models.Register, error) {
+	// Atoi would also take a leading +
+	if !isNumber(strings.TrimPrefix(token, "-")) {
+		return 0, fmt.Errorf("'%s' is not a number", token)
+	}
+	// A value too long to convert comes back as the largest or smallest int
+	value, _ := strconv.Atoi(token)
+	switch {
+	case value < 0:
+		return 0, fmt.Errorf("value %s is negative, mailboxes hold 0 to 999", token)
+	case value > 999:
+		return 0, fmt.Errorf("value %s exceeds maximum 999", token)
+	}
+	return models.Register(value), nil
+}
@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestValidateMachineCodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		size int
+		line int
+		col  int
+		msg  string
+	}{
+		{
+			name: "negative value",
+			src:  "901\n-5\n902",
+			line: 2, col: 1,
+			msg: "value -5 is negative, mailboxes hold 0 to 999",
+		},
+		{
+			name: "not a number",
+			src:  "901 abc 902",
+			line: 1, col: 5,
+			msg: "'abc' is not a number",
+		},
+		{
+			name: "too large",
+			src:  "901\n\t902 1000",
+			line: 2, col: 6,
+			msg: "value 1000 exceeds maximum 999",
+		},
+		{
+			name: "leading plus",
+			src:  "+5",
+			line: 1, col: 1,
+			msg: "'+5' is not a number",
+		},
+		{
+			name: "more than 100 values",
+			src:  strings.Repeat("000\n", 100) + "901",
+			line: 101, col: 1,
+			msg: "value 101 does not fit in 100 mailboxes",
+		},
+		{
+			name: "more than a smaller memory holds",
+			src:  "901 902 000",
+			size: 2,
+			line: 1, col: 9,
+			msg: "value 3 does not fit in 2 mailboxes",
+		},
+	}
+
+	for _, test := range tests {
+		_, _, err := ValidateMachineCode(strings.NewReader(test.src), test.size)
+		var asmErr *Error
+		if !errors.As(err, &asmErr) {
+			t.Errorf("%s: got %v, want an *Error", test.name, err)
+			continue
+		}
+		if asmErr.Line != test.line || asmErr.Col != test.col || asmErr.Msg != test.msg {
+			t.Errorf("%s: got line %d col %d %q, want line %d col %d %q", test.name, asmErr.Line, asmErr.Col, asmErr.Msg, test.line, test.col, test.msg)
+		}
+	}
+}
+
+func TestValidateMachineCode(t *testing.T) {
+	ram, warnings, err := ValidateMachineCode(strings.NewReader("901 ; read\n902\r\n000\n450"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.RAM{0: 901, 1: 902, 2: 0, 3: 450}
+	if !ram.Equal(want) {
+		t.Errorf("loaded %v, want %v", ram, want)
+	}
+	if len(warnings) != 1 || warnings[0].Line != 4 {
+		t.Errorf("warnings %v, want one about line 4", warnings)
+	}
+}
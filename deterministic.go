@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// verifyDeterministic runs the -file program twice, each time on a fresh CPU
+// with the same input, and checks that both runs end the same way. The
+// machine has no randomness, so any difference is a bug in the engine or in
+// how a CPU is set up. It exits with an error listing the differences
+func verifyDeterministic() {
+	requireFile("verify-deterministic")
+
+	program := compileFile()
+	first := newCPU(program)
+	first.Input = newInput(bufio.NewScanner(os.Stdin))
+	a := first.Result(first.Run())
+
+	// The second run reads exactly what the first did, even when that was
+	// typed at the terminal
+	var values vm.Values
+	for _, event := range first.IOLog() {
+		if event.Kind == vm.EventIn {
+			values = append(values, event.Value)
+		}
+	}
+	second := newCPU(program)
+	second.Input = &values
+	b := second.Result(second.Run())
+
+	if !resultsDiffer(os.Stdout, a, b) {
+		fmt.Printf("deterministic: both runs gave %d output(s), %d cycles and the same final memory\n", len(a.Outputs), a.Instructions)
+		return
+	}
+	os.Exit(1)
+}
+
+// resultsDiffer prints everything that differs between the results of two
+// runs of one program, and reports whether anything did
+func resultsDiffer(w io.Writer, a, b vm.RunResult) bool {
+	var diffs []string
+	if got, want := joinValues(b.Outputs), joinValues(a.Outputs); got != want {
+		diffs = append(diffs, fmt.Sprintf("outputs: first run %s, second run %s", orNone(want), orNone(got)))
+	}
+	if a.Error != b.Error {
+		diffs = append(diffs, fmt.Sprintf("error: first run %q, second run %q", a.Error, b.Error))
+	}
+	if a.Accumulator != b.Accumulator || a.Negative != b.Negative {
+		diffs = append(diffs, fmt.Sprintf("acc: first run %03d, second run %03d", a.Signed, b.Signed))
+	}
+	if a.PC != b.PC {
+		diffs = append(diffs, fmt.Sprintf("pc: first run %02d, second run %02d", a.PC, b.PC))
+	}
+	if a.Instructions != b.Instructions {
+		diffs = append(diffs, fmt.Sprintf("cycles: first run %d, second run %d", a.Instructions, b.Instructions))
+	}
+	for address := 0; address < len(a.Memory) && address < len(b.Memory); address++ {
+		if a.Memory[address] != b.Memory[address] {
+			diffs = append(diffs, fmt.Sprintf("mem[%02d]: first run %03d, second run %03d", address, a.Memory[address], b.Memory[address]))
+		}
+	}
+	if len(diffs) == 0 {
+		return false
+	}
+
+	fmt.Fprintln(w, "not deterministic: the two runs differ")
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "  %s\n", diff)
+	}
+	return true
+}
+
+// joinValues writes values as a comma separated list
+func joinValues(values []int) string {
+	written := make([]string, len(values))
+	for i, value := range values {
+		written[i] = strconv.Itoa(value)
+	}
+	return strings.Join(written, ", ")
+}
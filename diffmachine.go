@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// diffMachine compares two compiled programs mailbox by mailbox, printing
+// every mailbox that differs disassembled on both sides. It exits with an
+// error when they differ
+func diffMachine() {
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "diff-machine needs two programs: lmc diff-machine a.out b.out")
+		os.Exit(1)
+	}
+	a, b := flag.Arg(0), flag.Arg(1)
+	ramA, ramB := loadMachineFile(a), loadMachineFile(b)
+	if ramA.Equal(ramB) {
+		fmt.Printf("%s and %s are the same\n", a, b)
+		return
+	}
+
+	last := 0
+	for _, ram := range []models.RAM{ramA, ramB} {
+		if addresses := ram.SortedAddresses(); len(addresses) > 0 && addresses[len(addresses)-1] > last {
+			last = addresses[len(addresses)-1]
+		}
+	}
+
+	width := len(a)
+	if width < len("000 LDA 99") {
+		width = len("000 LDA 99")
+	}
+	fmt.Printf("Mailbox  %-*s  %s\n", width, a, b)
+	for address := 0; address <= last; address++ {
+		if ramA[address] == ramB[address] {
+			continue
+		}
+		fmt.Printf("%-7d  %-*s  %s\n", address, width, machineCell(ramA, address), machineCell(ramB, address))
+	}
+	os.Exit(1)
+}
+
+// loadMachineFile reads the compiled program at path into a memory of -size
+// mailboxes, exiting if it cannot
+func loadMachineFile(path string) models.RAM {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	ram, err := compiler.LoadMachineCode(f, *size)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return ram
+}
+
+// machineCell shows a mailbox as its value and the instruction it decodes as
+func machineCell(ram models.RAM, address int) string {
+	line := compiler.DisassembleMailbox(ram, address, nil)
+	return fmt.Sprintf("%03d %s", line.Value, line.Instruction())
+}
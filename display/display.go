@@ -0,0 +1,199 @@
+// Package display prints the state of the little man computer to the terminal
+package display
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// GridWidth is the number of columns the memory grid needs. A narrower
+// terminal gets a list of one mailbox per line instead
+const GridWidth = 80
+
+// rowLabel labels a row of the grid with the address of its first mailbox
+const rowLabel = "%2d"
+
+// PrintRegisters prints size mailboxes as a grid of rows of ten, or as a list
+// when width is narrower than GridWidth. A width of 0 always gets the grid
+func PrintRegisters(w io.Writer, ram models.RAM, size, width int) {
+	printMemory(w, ram, size, width, func(address int, cell string) string { return " " + cell + " " })
+}
+
+// printMemory prints the memory as a grid, or as a list when it is too
+// narrow for one, passing each mailbox's three digits through mark so they
+// can be decorated. mark returns the digits with a column either side, which
+// can hold a marker or a space
+func printMemory(w io.Writer, ram models.RAM, size, width int, mark func(address int, cell string) string) {
+	fmt.Fprintln(w, "Memory Registers")
+	fmt.Fprintln(w, "")
+	if width > 0 && width < GridWidth {
+		for address := 0; address < size; address++ {
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("%02d %s", address, mark(address, fmt.Sprintf("%03d", ram[address]))), " "))
+		}
+		return
+	}
+	printGrid(w, ram, size, mark)
+}
+
+// printGrid prints the rows of the memory grid. Each row is labelled with the
+// address of its first mailbox and each column with the units digit, so
+// mailbox 47 is in row 40 under column 7
+func printGrid(w io.Writer, ram models.RAM, size int, mark func(address int, cell string) string) {
+	// A cell is the three digits with two columns either side, the inner ones
+	// for markers, and the cells are divided by |. Nothing is printed after
+	// the last digit or marker so a row takes at most GridWidth columns
+	margin := strings.Repeat(" ", len(fmt.Sprintf(rowLabel, 0)))
+	header := make([]string, 10)
+	for column := range header {
+		header[column] = fmt.Sprintf("   %d   ", column)
+	}
+	separator := margin + strings.Repeat("-", GridWidth-len(margin))
+
+	fmt.Fprintln(w, strings.TrimRight(margin+strings.Join(header, " "), " "))
+	for row := 0; row*10 < size; row++ {
+		if row > 0 {
+			fmt.Fprintln(w, separator)
+		}
+
+		var cells []string
+		for address := row * 10; address < size && address < row*10+10; address++ {
+			cells = append(cells, " "+mark(address, fmt.Sprintf("%03d", ram[address]))+" ")
+		}
+		fmt.Fprintf(w, rowLabel+"%s\n", row*10, strings.TrimRight(strings.Join(cells, "|"), " "))
+	}
+}
+
+// PrintTitle prints the program's title and author, if its source gave them,
+// as a banner above the memory grid
+func PrintTitle(w io.Writer, program *models.Program) {
+	banner := program.Title
+	switch {
+	case banner == "" && program.Author == "":
+		return
+	case banner == "":
+		banner = "by " + program.Author
+	case program.Author != "":
+		banner += " by " + program.Author
+	}
+	fmt.Fprintln(w, banner)
+	fmt.Fprintln(w, "")
+}
+
+// PrintStatus prints the program counter, accumulator and cycle count on one line
+func PrintStatus(w io.Writer, cpu *vm.CPU) {
+	status := fmt.Sprintf("PC: %02d  ACC: %s  cycles: %d", cpu.PC, accumulator(cpu.Accumulator, cpu.Negative), cpu.Cost)
+	if cpu.CycleModel != nil {
+		status += fmt.Sprintf(" (%d instructions)", cpu.Cycles)
+	}
+	if cpu.Halted {
+		status += "  (halted)"
+	}
+	fmt.Fprintln(w, status)
+}
+
+// PrintStep prints one line of trace for an executed instruction
+func PrintStep(w io.Writer, step vm.StepResult) {
+	fmt.Fprintf(w, "%02d  %-7s  ACC: %s\n", step.PC, step.Instruction, accumulator(step.Accumulator, step.Negative))
+}
+
+// accumulator formats the accumulator, showing a negative result as a
+// negative number followed by the value actually stored
+func accumulator(acc int, neg bool) string {
+	if neg {
+		return fmt.Sprintf("%d (%03d)", vm.SignedAccumulator(acc, neg), acc)
+	}
+	return fmt.Sprintf("%03d", acc)
+}
+
+// PrintMix prints how many of each instruction a program holds, the most used
+// first, then the number of code and data cells
+func PrintMix(w io.Writer, mix map[models.Opcode]int, code, data int) {
+	opcodes := make([]models.Opcode, 0, len(mix))
+	for opcode := range mix {
+		opcodes = append(opcodes, opcode)
+	}
+	sort.Slice(opcodes, func(i, j int) bool {
+		if mix[opcodes[i]] != mix[opcodes[j]] {
+			return mix[opcodes[i]] > mix[opcodes[j]]
+		}
+		return opcodes[i] < opcodes[j]
+	})
+
+	fmt.Fprintln(w, "Instruction  Count")
+	for _, opcode := range opcodes {
+		fmt.Fprintf(w, "%-11s  %5d\n", opcode, mix[opcode])
+	}
+	fmt.Fprintf(w, "\nCode cells: %d\nData cells: %d\n", code, data)
+}
+
+// PrintSymbols prints the symbol table, one label per line in address order
+func PrintSymbols(w io.Writer, labels map[string]int) {
+	names := make([]string, 0, len(labels))
+	width := len("Label")
+	for name := range labels {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if labels[names[i]] != labels[names[j]] {
+			return labels[names[i]] < labels[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Fprintf(w, "%-*s  Address\n", width, "Label")
+	for _, name := range names {
+		fmt.Fprintf(w, "%-*s  %02d\n", width, name, labels[name])
+	}
+}
+
+// PrintAccessStats prints how many times each mailbox was read and written,
+// the busiest first, with its label when the program gives it one
+func PrintAccessStats(w io.Writer, stats map[int]vm.Access, program *models.Program) {
+	if len(stats) == 0 {
+		fmt.Fprintln(w, "No mailbox was read or written")
+		return
+	}
+
+	addresses := make([]int, 0, len(stats))
+	width := len("Label")
+	for address := range stats {
+		addresses = append(addresses, address)
+		if label := program.Label(address); len(label) > width {
+			width = len(label)
+		}
+	}
+	total := func(address int) int {
+		return stats[address].Reads + stats[address].Writes
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if total(addresses[i]) != total(addresses[j]) {
+			return total(addresses[i]) > total(addresses[j])
+		}
+		return addresses[i] < addresses[j]
+	})
+
+	fmt.Fprintf(w, "Mailbox  %-*s  Reads  Writes\n", width, "Label")
+	for _, address := range addresses {
+		fmt.Fprintf(w, "%-7s  %-*s  %5d  %6d\n", fmt.Sprintf("%02d", address), width, program.Label(address), stats[address].Reads, stats[address].Writes)
+	}
+}
+
+// PrintChanges prints what one step changed, one line per mailbox or register,
+// e.g. "step 12: mem[09] 000→042"
+func PrintChanges(w io.Writer, cycle int, changes []vm.Change) {
+	for _, change := range changes {
+		name := "acc"
+		if change.Mailbox >= 0 {
+			name = fmt.Sprintf("mem[%02d]", change.Mailbox)
+		}
+		fmt.Fprintf(w, "step %d: %s %03d→%03d\n", cycle, name, change.Before, change.After)
+	}
+}
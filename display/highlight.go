@@ -0,0 +1,187 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// colorReset is the ANSI escape code that ends a colored cell
+const colorReset = "\x1b[0m"
+
+// Theme is the set of ANSI escape codes the highlighted grid is colored with
+type Theme struct {
+	Name    string
+	PC      string
+	Changed string
+	Data    string
+	Code    string
+	Unused  string
+}
+
+// Themes are the palettes that can be picked by name. The colorblind theme
+// keeps clear of red and green, telling cells apart by blue, orange and
+// brightness instead. The high contrast theme avoids dim text, which some
+// terminals barely show
+var Themes = map[string]Theme{
+	"default": {
+		Name:    "default",
+		PC:      "\x1b[7m",
+		Changed: "\x1b[33m",
+		Data:    "\x1b[36m",
+		Code:    "\x1b[32m",
+		Unused:  "\x1b[2m",
+	},
+	"high-contrast": {
+		Name:    "high-contrast",
+		PC:      "\x1b[1;7m",
+		Changed: "\x1b[1;93m",
+		Data:    "\x1b[1;96m",
+		Code:    "\x1b[1;97m",
+		Unused:  "\x1b[90m",
+	},
+	"colorblind": {
+		Name:    "colorblind",
+		PC:      "\x1b[7m",
+		Changed: "\x1b[38;5;208m",
+		Data:    "\x1b[94m",
+		Code:    "\x1b[1m",
+		Unused:  "\x1b[2m",
+	},
+}
+
+// LookupTheme finds a theme by name. "" is the default theme
+func LookupTheme(name string) (Theme, error) {
+	if name == "" {
+		name = "default"
+	}
+	if theme, ok := Themes[strings.ToLower(name)]; ok {
+		return theme, nil
+	}
+
+	names := make([]string, 0, len(Themes))
+	for n := range Themes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Theme{}, fmt.Errorf("unknown color theme %q, choose one of %s", name, strings.Join(names, ", "))
+}
+
+// Highlight says which mailboxes the memory grid should pick out in color
+type Highlight struct {
+	// PC is the mailbox of the next instruction, or -1 for none
+	PC int
+	// Changed are the mailboxes the last step or run wrote to
+	Changed map[int]bool
+	// Program tells data cells from code. It may be nil
+	Program *models.Program
+	// Layout also picks out the code cells and the mailboxes the program
+	// does not use, showing how it is laid out in memory. It needs Program
+	Layout bool
+	// Theme is the palette to color with. The zero Theme is the default one
+	Theme Theme
+}
+
+// theme is the palette the grid is colored with
+func (h Highlight) theme() Theme {
+	if h.Theme.Name == "" {
+		return Themes["default"]
+	}
+	return h.Theme
+}
+
+// cellKind is what the program assembled into a mailbox
+type cellKind int
+
+const (
+	code cellKind = iota
+	data
+	unused
+)
+
+// kind finds what the program put in a mailbox. Without a program every
+// mailbox counts as code
+func (h Highlight) kind(address int) cellKind {
+	if h.Program == nil {
+		return code
+	}
+	source, ok := h.Program.Source[address]
+	switch {
+	case !ok:
+		return unused
+	case source.Data:
+		return data
+	}
+	return code
+}
+
+// PrintHighlighted prints the memory like PrintRegisters with the mailboxes
+// in h colored. In the default theme the program counter is in reverse video,
+// changed cells in yellow and data cells in cyan, and with h.Layout code
+// cells are green and unused mailboxes dim
+func PrintHighlighted(w io.Writer, ram models.RAM, size, width int, h Highlight) {
+	theme := h.theme()
+	printMemory(w, ram, size, width, func(address int, cell string) string {
+		color := ""
+		switch kind := h.kind(address); {
+		case h.Changed[address]:
+			color = theme.Changed
+		case kind == data:
+			color = theme.Data
+		case h.Layout && kind == code:
+			color = theme.Code
+		case h.Layout && kind == unused:
+			color = theme.Unused
+		}
+		// The program counter is picked out on top of whatever color the cell has
+		if address == h.PC {
+			color = theme.PC + color
+		}
+		if color == "" {
+			return " " + cell + " "
+		}
+		return " " + color + cell + colorReset + " "
+	})
+}
+
+// PrintMarked prints the memory like PrintHighlighted for output without
+// color, marking cells with a character either side of the digits: # before
+// data and . before unused mailboxes, and < after the program counter and *
+// after changed cells
+func PrintMarked(w io.Writer, ram models.RAM, size, width int, h Highlight) {
+	printMemory(w, ram, size, width, func(address int, cell string) string {
+		before, after := " ", " "
+		switch h.kind(address) {
+		case data:
+			before = "#"
+		case unused:
+			before = "."
+		}
+		switch {
+		case address == h.PC:
+			after = "<"
+		case h.Changed[address]:
+			after = "*"
+		}
+		return before + cell + after
+	})
+}
+
+// PrintLegend explains the colors PrintHighlighted uses in the theme,
+// including the code and unused colors when layout is set
+func PrintLegend(w io.Writer, layout bool, theme Theme) {
+	fmt.Fprintf(w, "Key: %s000%s next instruction  %s000%s changed  %s000%s data",
+		theme.PC, colorReset, theme.Changed, colorReset, theme.Data, colorReset)
+	if layout {
+		fmt.Fprintf(w, "  %s000%s code  %s000%s unused", theme.Code, colorReset, theme.Unused, colorReset)
+	}
+	fmt.Fprintln(w)
+}
+
+// PrintMarkedLegend explains the markers PrintMarked uses
+func PrintMarkedLegend(w io.Writer) {
+	fmt.Fprintln(w, "Key: 000< next instruction  000* changed  #000 data  .000 unused")
+}
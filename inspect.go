@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/display"
+)
+
+const inspectHelp = `Commands:
+  mem                     show the memory grid
+  list [from [to]]        disassemble mailboxes from to to (default the whole program)
+  labels                  show each label and the mailbox it names
+  set mem addr value      write a value into a mailbox and show what it now decodes as
+  save path               write memory to a file of mailbox values, as compile -higginson does
+  run                     open the step debugger on memory as it is now
+  help                    show this help
+  quit                    leave inspect`
+
+// inspector is a prompt for looking at and editing a program's memory
+// without running it. It shares the debugger's machine so run can hand the
+// edited memory straight over
+type inspector struct {
+	*repl
+}
+
+// inspectFile opens the memory editor on the -file program
+func inspectFile() {
+	requireFile("inspect")
+
+	program := compileFile()
+	scanner := bufio.NewScanner(os.Stdin)
+	cpu := newCPU(program)
+	cpu.Output = outputSink(os.Stdout)
+	cpu.Input = newInput(scanner)
+
+	r := &repl{cpu: cpu, program: program, scanner: scanner, out: os.Stdout, diff: *diff, breakOnOutput: *breakOnOutput}
+	i := inspector{r}
+	i.loop()
+}
+
+// loop reads commands until quit, run or the end of stdin
+func (i inspector) loop() {
+	display.PrintTitle(i.out, i.program)
+	i.showMemory()
+
+	for {
+		fmt.Fprint(i.out, "(inspect) ")
+		if !i.scanner.Scan() {
+			fmt.Fprintln(i.out)
+			return
+		}
+
+		fields := strings.Fields(i.scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !i.execute(strings.ToLower(fields[0]), fields[1:]) {
+			return
+		}
+	}
+}
+
+// execute runs one inspect command. It reports false when inspect should exit
+func (i inspector) execute(command string, args []string) bool {
+	switch command {
+	case "mem", "m":
+		i.showMemory()
+	case "list", "l":
+		i.list(args)
+	case "labels":
+		display.PrintSymbols(i.out, i.program.Labels)
+	case "set":
+		i.set(args)
+	case "save":
+		i.save(args)
+	case "run":
+		fmt.Fprintln(i.out, "starting the step debugger, type help for its commands")
+		i.repl.loop()
+		return false
+	case "help", "h", "?":
+		fmt.Fprintln(i.out, inspectHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Fprintf(i.out, "unknown command %q, type help for a list\n", command)
+	}
+	return true
+}
+
+// list disassembles a range of mailboxes, by default every mailbox the
+// program assembled
+func (i inspector) list(args []string) {
+	from, to := 0, len(i.program.Source)-1
+	if len(args) > 2 {
+		fmt.Fprintln(i.out, "list takes at most two mailboxes, e.g. list 10 20")
+		return
+	}
+	bounds := []*int{&from, &to}
+	for n, arg := range args {
+		address, err := strconv.Atoi(arg)
+		if err != nil || address < 0 || address >= i.cpu.Size {
+			fmt.Fprintf(i.out, "%q is not a mailbox from 0 to %d\n", arg, i.cpu.Size-1)
+			return
+		}
+		*bounds[n] = address
+	}
+	if len(args) == 1 {
+		to = from
+	}
+
+	for address := from; address <= to; address++ {
+		line := compiler.DisassembleMailbox(i.cpu.RAM, address, i.program)
+		fmt.Fprintf(i.out, "%02d  %03d  %s\n", address, line.Value, line)
+	}
+}
+
+// save writes memory as it is now to a file of mailbox values, which
+// validate -machine, -compare-machine and diff-machine can read
+func (i inspector) save(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(i.out, "save needs a file name, e.g. save prog.out")
+		return
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		fmt.Fprintf(i.out, "error: %v\n", err)
+		return
+	}
+	err = compiler.DumpHigginson(i.cpu.RAM, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(i.out, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(i.out, "saved to %s\n", args[0])
+}
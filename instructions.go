@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// helpTopic answers lmc help. The only topic so far is the instruction set
+func helpTopic() {
+	if topic := flag.Arg(0); topic != "instructions" {
+		fmt.Fprintln(os.Stderr, "help topics: lmc help instructions")
+		os.Exit(1)
+	}
+	printInstructions(os.Stdout)
+}
+
+// printInstructions lists every mnemonic the assembler accepts with its
+// machine code, whether it takes an operand and what it does
+func printInstructions(w io.Writer) {
+	fmt.Fprintf(w, "%-8s  %-4s  %-7s  %s\n", "Mnemonic", "Code", "Operand", "Description")
+	for _, m := range compiler.Mnemonics(selectedDialect()) {
+		operand := "no"
+		switch {
+		case m.Opcode == models.DAT:
+			operand = "value"
+		case m.Opcode.HasOperand():
+			operand = "mailbox"
+		}
+
+		description := m.Opcode.Description()
+		if m.Opcode == models.HLT {
+			// Decode treats every value below 100 as a halt
+			description += ", as does any 0xx"
+		}
+		if len(m.Aliases) > 0 {
+			description += fmt.Sprintf(" (also %s)", strings.Join(m.Aliases, ", "))
+		}
+		fmt.Fprintf(w, "%-8s  %-4s  %-7s  %s\n", m.Opcode, m.Code, operand, description)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// linkFiles assembles the source files named on the command line into one
+// program, each after the one before, and writes its machine code to -o or
+// the terminal. Where each file landed goes to stderr
+func linkFiles() {
+	paths := linkArgs()
+	if len(paths) < 2 {
+		fmt.Fprintln(os.Stderr, "link needs at least two source files: lmc link a.lmc b.lmc -o combined.out")
+		os.Exit(1)
+	}
+
+	program, err := compiler.Link(paths, compiler.Options{Strict: *strict, Size: *size, Dialect: selectedDialect()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "link error: %v\n", err)
+
+		var asmErr *compiler.Error
+		if errors.As(err, &asmErr) && asmErr.Underline() != "" {
+			fmt.Fprintln(os.Stderr, asmErr.Underline())
+		}
+		os.Exit(1)
+	}
+	for _, warning := range append(compiler.Lint(program), compiler.Analyze(program, false).Warnings...) {
+		warn(os.Stderr, warning)
+	}
+
+	out := os.Stdout
+	if *linkOutput != "" {
+		f, err := os.Create(*linkOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := compiler.DumpHigginson(program.RAM, out); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "linked %s\n", placements(program, paths))
+	}
+}
+
+// linkArgs returns the files given to link. Flags may come before, between
+// or after them, as in lmc link a.lmc b.lmc -o combined.out, so parsing
+// carries on past each file
+func linkArgs() []string {
+	var paths []string
+	args := flag.Args()
+	for len(args) > 0 {
+		paths = append(paths, args[0])
+		flag.CommandLine.Parse(args[1:])
+		args = flag.Args()
+	}
+	return paths
+}
+
+// placements describes the mailboxes each linked file ended up in, such as
+// "a.lmc at 00-09 and b.lmc at 10-20". The data CALL adds at the end of the
+// program counts towards the file holding the CALL
+func placements(program *models.Program, paths []string) string {
+	cells := make(map[string][]int)
+	for _, address := range program.RAM.SortedAddresses() {
+		if source, ok := program.Source[address]; ok {
+			cells[source.File] = append(cells[source.File], address)
+		}
+	}
+
+	parts := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if len(cells[path]) == 0 {
+			parts = append(parts, fmt.Sprintf("%s (no mailboxes)", path))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s at %s", path, compiler.AddressRanges(cells[path])))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
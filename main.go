@@ -2,52 +2,902 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/display"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
 )
 
 var (
 	// Flags for the CLI
-	file = flag.String("file", "", "Include the name of a file with the assembly code")
-	// state models.RAM
+	file             = flag.String("file", "", "Include the name of a file with the assembly code")
+	machine          = flag.String("machine", "", "Compiled program of mailbox values for validate to check")
+	linkOutput       = flag.String("o", "", "File lmc link writes the combined program to, instead of the terminal")
+	higginson        = flag.Bool("higginson", false, "Print the compiled program in the format of Peter Higginson's LMC simulator")
+	strict           = flag.Bool("strict", false, "Require an explicit HLT and reject code that runs into data or blank mailboxes")
+	allowEmpty       = flag.Bool("allow-empty", false, "Run a program that is nothing but HLT without warning that it is empty")
+	failOnWarning    = flag.Bool("fail-on-warning", false, "Assemble as usual but exit with an error if any warning was printed. Unlike -strict it does not change what assembles")
+	pseudo           = flag.Bool("pseudo", false, "Print the compiled program as pseudocode with variables named after labels")
+	summary          = flag.Bool("summary", false, "Print how many of each instruction the program holds and its code and data cell counts")
+	sourceMap        = flag.String("sourcemap", "", "Also write a JSON source map from each mailbox to its source line and column, for debuggers")
+	annotateSource   = flag.Bool("annotate-source", false, "Print the source with the mailbox and machine code of each line added as a comment, still valid to assemble")
+	dumpASM          = flag.Bool("dump-asm", false, "After a run, print the final memory as assembly instead of the memory grid, showing self-modified cells")
+	compareMachine   = flag.String("compare-machine", "", "After a run, compare the final memory with a machine code file and fail listing the mailboxes that differ")
+	symbols          = flag.Bool("symbols", false, "Print each label and the mailbox it resolves to instead of the memory grid")
+	optimize         = flag.Bool("optimize", false, "Remove zero DAT cells at the end of the program that nothing refers to")
+	diff             = flag.Bool("diff", false, "Log the mailboxes and accumulator changed by every instruction")
+	dumpEveryStep    = flag.Bool("dump-every-step", false, "Print the whole memory grid and registers after every instruction of the run. Very long, meant for teaching material")
+	breakOnOutput    = flag.Bool("break-on-output", false, "In the step debugger, make continue stop after every OUT")
+	warnUninit       = flag.Bool("warn-uninit", false, "Warn the first time the run reads a data cell declared with a bare DAT that nothing has stored to")
+	warnHLTOverwrite = flag.Bool("warn-hlt-overwrite", false, "Warn when the run stores over a mailbox the program assembled as HLT, without stopping it")
+	seedMemory       = flag.String("seed-memory", "", "Set mailboxes after loading the program, e.g. 90=5,91=3")
+	seedAcc          = flag.String("seed-acc", "", "Value from 0 to 999 to put in the accumulator before the first instruction")
+	size             = flag.Int("size", models.MaxSize, "Number of mailboxes, from 1 to 100, for variants with a smaller memory")
+	dialectName      = flag.String("dialect", models.Standard.Name, "Instruction set to assemble and run: standard, or higginson for Peter Higginson's simulator with OTC")
+	start            = flag.String("start", "", "Mailbox or label the run starts at instead of mailbox 0. It must hold an instruction")
+	input            = flag.String("input", "", "Comma separated values for INP, e.g. 5,3. Without it INP asks for each value")
+	inputFile        = flag.String("input-file", "", "Read the values for INP from a file of whitespace separated numbers")
+	randomInput      = flag.Int("random-input", 0, "Feed INP this many random values from 0 to 999, printed to stderr. -seed makes them the same every run")
+	inputSeed        = flag.Int64("seed", 0, "Seed for -random-input. 0 picks one from the clock, printed so the run can be repeated")
+	echoInput        = flag.Bool("echo-input", false, "Echo each value typed for INP into the output as INP: n, for a complete transcript")
+	outputFile       = flag.String("output-file", "", "Write the values sent to OUT to a file, one per line, instead of the terminal")
+	numberOutput     = flag.Bool("number-output", false, "Prefix each value sent to OUT with its number, e.g. #1: 42")
+	color            = flag.Bool("color", false, "Color the memory grid: the next instruction, cells changed by the run and data cells")
+	colorTheme       = flag.String("color-theme", "", "Palette for the colored grid: default, high-contrast or colorblind. Colors a terminal without -color; -color forces color into files and pipes")
+	layout           = flag.Bool("layout", false, "Mark code, data and unused cells in the memory grid, in color with -color and with # and . markers without")
+	legend           = flag.Bool("legend", false, "With -color, print the key to the colors under every memory grid, not just the first")
+	gridWidth        = flag.Int("width", 0, "Columns to lay out the memory for. Below 80 it is listed one mailbox per line. 0 means the terminal width")
+	quiet            = flag.Bool("quiet", false, "Only print the values sent to OUT: no memory grid and no INP prompts")
+	jsonOut          = flag.Bool("json", false, "Print the result of the run as JSON. Takes precedence over -quiet")
+	jsonPretty       = flag.Bool("json-pretty", false, "Like -json but with the JSON indented for reading. -trace-json stays one object per line")
+	report           = flag.Bool("report", false, "After a run, print one report of the outputs, accumulator, cycles, coverage, I/O and warnings. With -json, as JSON")
+	accessStats      = flag.Bool("access-stats", false, "After a run, list how many times each mailbox was read by LDA, ADD and SUB and written by STA, the busiest first")
+	transcript       = flag.String("transcript", "", "Also write a record of the run to a file: the source with addresses, every INP and OUT, warnings and the final state")
+	maxOutput        = flag.Int("max-output", 100000, "Stop the run with an error once OUT has sent this many values. 0 means no limit")
+	maxCycles        = flag.Int("max-cycles", 1000000, "Stop the run with an error after this many instructions. 0 means no limit")
+	noWrap           = flag.Bool("no-wrap", false, "Stop the run with an error when ADD goes past 999 or SUB below 0, instead of wrapping round and setting the negative flag")
+	cycleWeights     = flag.String("cycle-weights", "", "Cost in cycles of each instruction, e.g. INP=5,OUT=5. Instructions left out cost 1")
+	runFor           = flag.Int("run-for", 0, "Stop the run after this many instructions and show the machine state")
+	traceJSON        = flag.String("trace-json", "", "Write the state after every instruction to a file, one JSON object per line, for lmc replay")
+	traceSteps       = flag.Bool("trace", false, "Log every instruction the run executes with the accumulator after it, to stderr")
+	stepDelay        = flag.Duration("step-delay", 0, "Pause between the states lmc replay shows, e.g. 500ms")
 )
 
 func main() {
-	flag.Parse()
-
 	if len(os.Args) <= 1 {
 		// User needs to enter an argument
 		fmt.Println("User needs to enter an argument")
 		os.Exit(1)
 	}
 
+	// Flags come after the command, e.g. lmc compile -file prog.lmc
 	arg := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+	if *jsonPretty {
+		*jsonOut = true
+	}
+	if len(traceRanges) > 0 {
+		*traceSteps = true
+	}
 	parseArgs(arg)
+
+	if *failOnWarning && len(printedWarnings) > 0 {
+		fmt.Fprintf(os.Stderr, "error: %d warning(s) printed and -fail-on-warning is set\n", len(printedWarnings))
+		os.Exit(1)
+	}
+}
+
+// printedWarnings are the warnings printed so far, for -fail-on-warning and
+// -report
+var printedWarnings []string
+
+// warn prints a warning and keeps it
+func warn(w io.Writer, warning interface{}) {
+	printedWarnings = append(printedWarnings, fmt.Sprint(warning))
+	fmt.Fprintf(teeTranscript(w), "warning: %v\n", warning)
 }
 
 func parseArgs(arg string) {
 	switch strings.ToLower(arg) {
 	case "compile":
-		// state = compiler.Compile("test")
-		// fmt.Println("RAM: %v", state)
-
 		if *file == "" {
-			fmt.Println("COMPILE from args")
-		} else {
-			fmt.Println("COMPILE from file")
-			// compiler.CompileTerminalInput()
+			startShell()
+			return
+		}
+
+		program := compileFile()
+
+		if *sourceMap != "" {
+			if err := writeSourceMap(*sourceMap, program); err != nil {
+				fmt.Fprintf(os.Stderr, "error: -sourcemap: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if *annotateSource {
+			if err := annotateFile(*file, program); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if *higginson {
+			if err := compiler.DumpHigginson(program.RAM, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if *symbols {
+			display.PrintSymbols(os.Stdout, program.Labels)
+			return
+		}
+
+		if *pseudo {
+			fmt.Print(compiler.Pseudocode(program))
+			return
 		}
 
-		compiler.CompileTerminalInput()
+		if *summary {
+			report := compiler.Analyze(program, *seedAcc != "")
+			display.PrintMix(os.Stdout, report.Mix, report.CodeCells, report.DataCells)
+			return
+		}
+
+		// Only a -start away from mailbox 0 is worth marking
+		pc := -1
+		if *start != "" {
+			pc = newCPU(program).PC
+		}
+		display.PrintTitle(os.Stdout, program)
+		showMemory(os.Stdout, program.RAM, program.Size, display.Highlight{PC: pc, Program: program})
+	case "repl":
+		startShell()
+	case "new":
+		newProgram()
 	case "run":
-		fmt.Println("RUN")
+		runFile()
 	case "step":
-		fmt.Println("STEP")
+		stepFile()
+	case "debug":
+		debugFile()
+	case "inspect":
+		inspectFile()
+	case "info":
+		infoFile()
+	case "bench":
+		benchFile()
+	case "replay":
+		replayTrace()
+	case "validate":
+		validateFile()
+	case "diff-machine":
+		diffMachine()
+	case "link":
+		linkFiles()
+	case "verify-deterministic":
+		verifyDeterministic()
+	case "instructions":
+		printInstructions(os.Stdout)
+	case "help":
+		helpTopic()
 	default:
 		fmt.Println("ERROR: bad command \nShow HELP")
 	}
 }
+
+// runFile runs the -file program to the end and shows the final memory
+func runFile() {
+	requireFile("run")
+
+	program := compileFile()
+	if !*allowEmpty && isEmpty(program.RAM) {
+		warn(os.Stderr, "program is empty (halts immediately)")
+	}
+	var record *os.File
+	if *transcript != "" {
+		var err error
+		if record, err = openTranscript(*transcript, program); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -transcript: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	cpu := newCPU(program)
+	cpu.Input = recordInput(newInput(bufio.NewScanner(os.Stdin)))
+
+	// A terminal gets every OUT the moment it happens so interactive programs
+	// can prompt and print as they go. Files and pipes are buffered
+	var buffered *bufio.Writer
+	switch {
+	case *outputFile != "":
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -output-file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		buffered = bufio.NewWriter(f)
+		cpu.Output = buffered
+	case *jsonOut:
+	// The state dumps go straight to stdout, so OUT must too to keep the order
+	case isTerminal(os.Stdout) || *dumpEveryStep:
+		cpu.Output = os.Stdout
+	default:
+		buffered = bufio.NewWriter(os.Stdout)
+		cpu.Output = buffered
+	}
+
+	cpu.Output = teeTranscript(outputSink(cpu.Output))
+	start := cpu.Snapshot()
+
+	var err error
+	var steps []vm.StepResult
+	switch {
+	case *runFor > 0 || *diff || *dumpEveryStep || *traceSteps || *traceJSON != "" || *warnUninit || *warnHLTOverwrite || len(watchMailboxes) > 0:
+		err = runInterruptible(cpu, buffered, func(ctx context.Context) error {
+			var runErr error
+			steps, runErr = runStepwise(ctx, cpu, program)
+			return runErr
+		})
+	default:
+		err = runInterruptible(cpu, buffered, cpu.RunContext)
+	}
+
+	if buffered != nil {
+		if flushErr := buffered.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+
+	if !*quiet && !*jsonOut {
+		for _, step := range steps {
+			display.PrintStep(os.Stdout, step)
+		}
+	}
+
+	switch {
+	case *report && *jsonOut:
+		if encodeErr := jsonEncoder(os.Stdout).Encode(newRunReport(program, cpu, err)); encodeErr != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", encodeErr)
+			os.Exit(1)
+		}
+	case *report:
+		newRunReport(program, cpu, err).print(os.Stdout)
+	case *jsonOut:
+		encoder := jsonEncoder(os.Stdout)
+		result := cpu.Result(err)
+		result.Title, result.Author = program.Title, program.Author
+		if encodeErr := encoder.Encode(result); encodeErr != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", encodeErr)
+			os.Exit(1)
+		}
+	case *dumpASM:
+		dumpAssembly(os.Stdout, cpu.RAM, program)
+	case !*quiet:
+		display.PrintTitle(os.Stdout, program)
+		showMemory(os.Stdout, cpu.RAM, cpu.Size, display.Highlight{
+			PC:      cpu.PC,
+			Changed: changedMailboxes(vm.Diff(start, cpu.Snapshot())),
+			Program: program,
+		})
+		display.PrintStatus(os.Stdout, cpu)
+	}
+
+	if *accessStats {
+		// Kept out of the way of the JSON, which is meant to be parsed
+		w := io.Writer(os.Stdout)
+		if *jsonOut {
+			w = os.Stderr
+		} else {
+			fmt.Println()
+		}
+		display.PrintAccessStats(w, cpu.AccessStats(), program)
+	}
+
+	if record != nil {
+		if transcriptErr := closeTranscript(record, cpu, err); transcriptErr != nil {
+			fmt.Fprintf(os.Stderr, "error: -transcript: %v\n", transcriptErr)
+			os.Exit(1)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if *compareMachine != "" && !compareMemory(os.Stderr, cpu.RAM, program.Size, *compareMachine) {
+		os.Exit(1)
+	}
+}
+
+// isEmpty reports whether every mailbox of ram is 0, so the run would do
+// nothing but halt at mailbox 0
+func isEmpty(ram models.RAM) bool {
+	for _, value := range ram {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareMemory checks memory after a run against the machine code file at
+// path, listing every mailbox of the size mailboxes of memory that differs.
+// It reports whether they matched
+func compareMemory(w io.Writer, ram models.RAM, size int, path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(w, "error: -compare-machine: %v\n", err)
+		return false
+	}
+	defer f.Close()
+
+	expected, err := compiler.LoadMachineCode(f, size)
+	if err != nil {
+		fmt.Fprintf(w, "error: -compare-machine: %s: %v\n", path, err)
+		return false
+	}
+	if ram.Equal(expected) {
+		return true
+	}
+
+	fmt.Fprintf(w, "final memory does not match %s:\n", path)
+	for address := 0; address < size; address++ {
+		if got, want := ram[address], expected[address]; got != want {
+			fmt.Fprintf(w, "  mem[%02d] expected %03d, got %03d\n", address, want, got)
+		}
+	}
+	return false
+}
+
+// jsonEncoder encodes the JSON a command prints, compact for pipes unless
+// -json-pretty asks for it indented
+func jsonEncoder(w io.Writer) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	if *jsonPretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder
+}
+
+// writeSourceMap writes the program's source map to path as JSON
+func writeSourceMap(path string, program *models.Program) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(compiler.NewSourceMap(program)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// annotateFile prints the source in path with the mailbox of each line added
+func annotateFile(path string, program *models.Program) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return compiler.Annotate(f, program, os.Stdout)
+}
+
+// dumpAssembly prints memory after a run as assembly source. Mailboxes the run
+// changed are written as DAT with their new value, since whatever they hold
+// now may not be meant as an instruction, and a comment gives what they were
+func dumpAssembly(w io.Writer, ram models.RAM, program *models.Program) {
+	addresses := ram.SortedAddresses()
+	if len(addresses) == 0 {
+		return
+	}
+
+	for address := 0; address <= addresses[len(addresses)-1]; address++ {
+		line := compiler.DisassembleMailbox(ram, address, program)
+		original, ok := program.RAM[address]
+		if ok && original == ram[address] {
+			fmt.Fprintln(w, line)
+			continue
+		}
+
+		line.Opcode, line.Operand = models.DAT, strconv.Itoa(int(ram[address]))
+		if _, written := ram[address]; !ok && !written {
+			// A gap between mailboxes, kept so the rest keep their addresses
+			fmt.Fprintln(w, line)
+			continue
+		}
+		if !ok {
+			fmt.Fprintf(w, "%-20s; written by the run\n", line)
+			continue
+		}
+		was := compiler.DisassembleMailbox(program.RAM, address, program)
+		fmt.Fprintf(w, "%-20s; changed by the run, was %s\n", line, was.Instruction())
+	}
+}
+
+// runInterruptible runs the CPU with run until it halts or the user presses
+// Ctrl-C. On Ctrl-C it writes out the OUT values still held in out, shows
+// where the program had got to, in the transcript as well when there is one,
+// and exits with status 130. out may be nil
+func runInterruptible(cpu *vm.CPU, out *bufio.Writer, run func(context.Context) error) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// The run notices on its next step. If it does not come back it is
+		// waiting for INP and is left behind as we exit
+		select {
+		case err = <-done:
+		case <-time.After(100 * time.Millisecond):
+			err = ctx.Err()
+		}
+	}
+	if !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	if out != nil {
+		out.Flush()
+	}
+	stderr := teeTranscript(os.Stderr)
+	fmt.Fprintln(stderr, "\ninterrupted")
+	display.PrintStatus(stderr, cpu)
+	outputs := make([]string, len(cpu.Outputs()))
+	for i, value := range cpu.Outputs() {
+		outputs[i] = strconv.Itoa(value)
+	}
+	fmt.Fprintf(stderr, "outputs: %s\n", strings.Join(outputs, ", "))
+	os.Exit(130)
+	return nil
+}
+
+// runStepwise runs the CPU one step at a time. With -diff it logs what each
+// step changed to stderr so it stays apart from the program's own output,
+// -trace logs the steps inside the -trace-range windows there too, and with
+// -trace-json it records every step to the trace file. -warn-uninit
+// warns on stderr about reads of data cells that were never given a value,
+// -warn-hlt-overwrite warns when a store replaces one of the program's HLTs,
+// and -watch-mailbox logs the steps that change the watched mailboxes. All of
+// it goes into the -transcript too. With -run-for it stops after that many
+// steps and returns them, to be shown once the run is over. It returns
+// ctx.Err() once ctx is cancelled
+func runStepwise(ctx context.Context, cpu *vm.CPU, program *models.Program) ([]vm.StepResult, error) {
+	stdout, stderr := teeTranscript(os.Stdout), teeTranscript(os.Stderr)
+	var trace *json.Encoder
+	if *traceJSON != "" {
+		f, err := os.Create(*traceJSON)
+		if err != nil {
+			return nil, fmt.Errorf("-trace-json: %v", err)
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		defer w.Flush()
+		trace = json.NewEncoder(w)
+	}
+
+	for _, address := range watchMailboxes {
+		if address >= cpu.Size {
+			return nil, fmt.Errorf("-watch-mailbox: mailbox %d is outside the %d mailboxes of memory", address, cpu.Size)
+		}
+	}
+	for _, window := range traceRanges {
+		if window.First >= cpu.Size {
+			return nil, fmt.Errorf("-trace-range: mailbox %d is outside the %d mailboxes of memory", window.First, cpu.Size)
+		}
+	}
+
+	var uninit *uninitWatch
+	if *warnUninit {
+		uninit = newUninitWatch(program, cpu.Size)
+	}
+	var hlt *hltWatch
+	if *warnHLTOverwrite {
+		hlt = newHLTWatch(program)
+	}
+	if *dumpEveryStep {
+		fmt.Fprintln(stdout, "Start")
+		dumpState(stdout, cpu, program, nil)
+	}
+
+	var steps []vm.StepResult
+	for !cpu.Halted && (*runFor <= 0 || len(steps) < *runFor) {
+		if err := ctx.Err(); err != nil {
+			return steps, err
+		}
+		if uninit != nil {
+			uninit.before(cpu, os.Stderr)
+		}
+		before := cpu.Snapshot()
+		step, err := cpu.Step()
+		if err != nil {
+			return steps, err
+		}
+		if *runFor > 0 {
+			steps = append(steps, step)
+		}
+		if uninit != nil {
+			uninit.after(step)
+		}
+		if *traceSteps && traced(step.PC) {
+			display.PrintStep(stderr, step)
+		}
+		if hlt != nil {
+			hlt.after(step, cpu, os.Stderr)
+		}
+		changes := vm.Diff(before, cpu.Snapshot())
+		switch {
+		case *diff:
+			display.PrintChanges(stderr, cpu.Cycles, changes)
+		case len(watchMailboxes) > 0:
+			display.PrintChanges(stderr, cpu.Cycles, watched(changes))
+		}
+		if *dumpEveryStep {
+			fmt.Fprintf(stdout, "\nCycle %d: %s in mailbox %02d\n", cpu.Cycles, step.Instruction, step.PC)
+			dumpState(stdout, cpu, program, changedMailboxes(changes))
+		}
+		if trace != nil {
+			if err := trace.Encode(cpu.Trace(step)); err != nil {
+				return steps, fmt.Errorf("-trace-json: %v", err)
+			}
+		}
+	}
+	return steps, nil
+}
+
+// dumpState prints the memory grid and registers for -dump-every-step,
+// picking out the mailboxes the last instruction changed
+func dumpState(w io.Writer, cpu *vm.CPU, program *models.Program, changed map[int]bool) {
+	// Each grid is only worth having with the next instruction and the
+	// changes picked out, in color or with markers
+	showMemoryMarked(w, cpu.RAM, cpu.Size, display.Highlight{PC: cpu.PC, Changed: changed, Program: program}, true)
+	display.PrintStatus(w, cpu)
+}
+
+// newCPU loads the program into a CPU of the right size and limits, starting
+// at the -start mailbox, then applies the -seed-memory values on top of it and
+// puts the -seed-acc value in the accumulator
+func newCPU(program *models.Program) *vm.CPU {
+	cpu := vm.New(program.RAM)
+	cpu.Size = program.Size
+	cpu.Program = program
+	cpu.Dialect = program.Dialect
+	if *start != "" {
+		address, err := startAddress(program, *start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -start: %v\n", err)
+			os.Exit(1)
+		}
+		cpu.PC = address
+	}
+	cpu.MaxOutputs = *maxOutput
+	cpu.MaxCycles = *maxCycles
+	cpu.NoWrap = *noWrap
+	if *cycleWeights != "" {
+		model, err := vm.ParseCycleModel(*cycleWeights)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -cycle-weights: %v\n", err)
+			os.Exit(1)
+		}
+		cpu.CycleModel = model
+	}
+
+	if *seedMemory != "" {
+		seeds, err := parseSeeds(*seedMemory, cpu.Size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -seed-memory: %v\n", err)
+			os.Exit(1)
+		}
+		for address, value := range seeds {
+			cpu.RAM[address] = models.Register(value)
+		}
+	}
+
+	if *seedAcc != "" {
+		value, err := vm.ParseValue(*seedAcc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -seed-acc: %v\n", err)
+			os.Exit(1)
+		}
+		cpu.Accumulator = value
+	}
+	return cpu
+}
+
+// startAddress finds the mailbox a run starts at, given as a number or a label.
+// It must hold an instruction the program assembled, not data
+func startAddress(program *models.Program, s string) (int, error) {
+	address, err := strconv.Atoi(s)
+	if err != nil {
+		var ok bool
+		if address, ok = program.Labels[s]; !ok {
+			return 0, fmt.Errorf("%q is not a mailbox or a label", s)
+		}
+	}
+
+	source, ok := program.Source[address]
+	switch {
+	case address < 0 || address >= program.Size:
+		return 0, fmt.Errorf("mailbox %d is outside the %d mailboxes of memory", address, program.Size)
+	case !ok:
+		return 0, fmt.Errorf("mailbox %d is not part of the program", address)
+	case source.Data:
+		return 0, fmt.Errorf("mailbox %d holds data, not an instruction", address)
+	}
+	return address, nil
+}
+
+// parseSeeds parses mailbox=value pairs such as 90=5,91=3
+func parseSeeds(s string, size int) (map[int]int, error) {
+	seeds := make(map[int]int)
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not mailbox=value", field)
+		}
+
+		address, err := strconv.Atoi(parts[0])
+		if err != nil || address < 0 || address >= size {
+			return nil, fmt.Errorf("%q is not a mailbox from 0 to %d", parts[0], size-1)
+		}
+		value, err := vm.ParseValue(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		seeds[address] = value
+	}
+	return seeds, nil
+}
+
+// newInput picks where INP reads from: the -input values, -input-file or
+// -random-input, otherwise lines of stdin read through scanner, prompting for
+// each value when stdin is a terminal and the output is for a person.
+// -echo-input repeats each value read from stdin into the output
+func newInput(scanner *bufio.Scanner) vm.Input {
+	given := 0
+	for _, set := range []bool{*input != "", *inputFile != "", *randomInput > 0} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		fmt.Fprintln(os.Stderr, "error: use only one of -input, -input-file and -random-input")
+		os.Exit(1)
+	}
+
+	if *randomInput > 0 {
+		seed := *inputSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		values := vm.RandomValues(*randomInput, seed)
+		written := make([]string, len(values))
+		for i, value := range values {
+			written[i] = strconv.Itoa(value)
+		}
+		// Enough to run exactly the same again with -seed or -input
+		fmt.Fprintf(os.Stderr, "random input, -seed %d: %s\n", seed, strings.Join(written, ","))
+		return &values
+	}
+
+	if *inputFile != "" {
+		values, err := readValuesFile(*inputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -input-file: %v\n", err)
+			os.Exit(1)
+		}
+		return &values
+	}
+
+	if *input != "" {
+		values, err := parseValues(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -input: %v\n", err)
+			os.Exit(1)
+		}
+		return &values
+	}
+
+	in := &vm.LineInput{Scanner: scanner}
+	if isTerminal(os.Stdin) && !*quiet && !*jsonOut {
+		in.Prompt = os.Stdout
+	}
+	// The echo would break the JSON document on stdout
+	if *echoInput && !*jsonOut {
+		in.Echo = os.Stdout
+	}
+	return in
+}
+
+// readValuesFile reads the INP values held in a file
+func readValuesFile(path string) (vm.Values, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values, err := vm.ReadValues(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+// parseValues parses a comma separated list of mailbox values
+func parseValues(s string) (vm.Values, error) {
+	var values vm.Values
+	for _, field := range strings.Split(s, ",") {
+		value, err := vm.ParseValue(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// legendShown records that the key to the grid colors has been printed
+var legendShown bool
+
+// showMemory prints the memory grid, colored with -color, or on a terminal
+// with -color-theme. -layout also picks out code, data and unused cells, with
+// markers instead when there is no color. The key follows the first
+// highlighted grid, or every one with -legend. A terminal too narrow for the
+// grid, or a narrow -width, gets a list instead. Output that is not a
+// terminal is taken to be 80 columns wide
+func showMemory(w io.Writer, ram models.RAM, size int, h display.Highlight) {
+	showMemoryMarked(w, ram, size, h, *layout)
+}
+
+// showMemoryMarked is showMemory that marks the cells without color whenever
+// marked is set, not only with -layout
+func showMemoryMarked(w io.Writer, ram models.RAM, size int, h display.Highlight, marked bool) {
+	width := *gridWidth
+	if width == 0 {
+		width = display.GridWidth
+		if columns := terminalWidth(os.Stdout); columns > 0 {
+			width = columns
+		}
+	}
+
+	theme, err := display.LookupTheme(*colorTheme)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -color-theme: %v\n", err)
+		os.Exit(1)
+	}
+	colored := *color || *colorTheme != "" && isTerminal(os.Stdout)
+
+	h.Layout, h.Theme = *layout, theme
+	switch {
+	case colored:
+		display.PrintHighlighted(w, ram, size, width, h)
+	case marked:
+		display.PrintMarked(w, ram, size, width, h)
+	default:
+		display.PrintRegisters(w, ram, size, width)
+		return
+	}
+
+	if *legend || !legendShown {
+		if colored {
+			display.PrintLegend(w, *layout, theme)
+		} else {
+			display.PrintMarkedLegend(w)
+		}
+		legendShown = true
+	}
+}
+
+// changedMailboxes picks the mailboxes out of a list of changes
+func changedMailboxes(changes []vm.Change) map[int]bool {
+	changed := make(map[int]bool)
+	for _, change := range changes {
+		if change.Mailbox >= 0 {
+			changed[change.Mailbox] = true
+		}
+	}
+	return changed
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// infoFile prints what static analysis can tell about the -file program
+func infoFile() {
+	requireFile("info")
+
+	program := compileFile()
+	report := compiler.Analyze(program, *seedAcc != "")
+
+	halts := "no"
+	if report.Halts {
+		halts = "yes"
+	}
+	fmt.Printf("INP instructions: %d\n", report.Inputs)
+	fmt.Printf("OUT instructions: %d\n", report.Outputs)
+	fmt.Printf("Has HLT: %s\n", halts)
+
+	var referenced []string
+	for _, address := range report.Referenced {
+		if label := program.Label(address); label != "" {
+			referenced = append(referenced, fmt.Sprintf("%02d (%s)", address, label))
+		} else {
+			referenced = append(referenced, fmt.Sprintf("%02d", address))
+		}
+	}
+	fmt.Printf("Referenced mailboxes: %s\n", strings.Join(referenced, ", "))
+}
+
+// requireFile exits with a usage hint when command was not given a -file
+func requireFile(command string) {
+	if *file == "" {
+		fmt.Fprintf(os.Stderr, "%s needs a program: lmc %s -file prog.lmc\n", command, command)
+		os.Exit(1)
+	}
+}
+
+// selectedDialect is the dialect named by -dialect. It exits when there is no
+// such dialect
+func selectedDialect() models.Dialect {
+	dialect, err := models.LookupDialect(*dialectName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -dialect: %v\n", err)
+		os.Exit(1)
+	}
+	return dialect
+}
+
+// compileFile assembles the -file program, printing any lint warnings. It exits
+// when the program does not assemble
+func compileFile() *models.Program {
+	program, err := compiler.CompileFromFile(*file, compiler.Options{Strict: *strict, Size: *size, Dialect: selectedDialect()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "compile error: %v\n", err)
+
+		var asmErr *compiler.Error
+		if errors.As(err, &asmErr) && asmErr.Underline() != "" {
+			fmt.Fprintln(os.Stderr, asmErr.Underline())
+		}
+		os.Exit(1)
+	}
+
+	warnings := append(compiler.Lint(program), compiler.Analyze(program, *seedAcc != "").Warnings...)
+	for _, warning := range warnings {
+		warn(os.Stderr, warning)
+	}
+
+	if *optimize {
+		removed := compiler.Optimize(program)
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "optimize: removed %d unused DAT cells\n", removed)
+		}
+	}
+	return program
+}
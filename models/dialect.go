@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OTC sends the accumulator to the out tray as a character rather than a
+// number. It is an extension, only there in dialects that have it
+const OTC Opcode = "OTC"
+
+// Dialect is one of the variants of the instruction set found in LMC
+// simulators: the spellings the assembler accepts and the instructions added
+// to the standard ones
+type Dialect struct {
+	Name string
+	// Aliases are other spellings accepted for an instruction
+	Aliases map[string]Opcode
+	// Extensions are instructions beyond the standard set, with the machine
+	// code each takes. None of them has an operand
+	Extensions map[Opcode]Register
+}
+
+// Standard is the instruction set as it is usually taught, and the default
+var Standard = Dialect{
+	Name:    "standard",
+	Aliases: map[string]Opcode{"COB": HLT, "IN": INP},
+}
+
+// Higginson is the instruction set of Peter Higginson's online LMC simulator,
+// which adds OTC for printing characters
+var Higginson = Dialect{
+	Name:       "higginson",
+	Aliases:    map[string]Opcode{"COB": HLT, "IN": INP},
+	Extensions: map[Opcode]Register{OTC: 922},
+}
+
+// Dialects are the dialects that can be selected by name
+var Dialects = map[string]Dialect{
+	Standard.Name:  Standard,
+	Higginson.Name: Higginson,
+}
+
+// LookupDialect finds a dialect by name in any case
+func LookupDialect(name string) (Dialect, error) {
+	if d, ok := Dialects[strings.ToLower(name)]; ok {
+		return d, nil
+	}
+
+	names := make([]string, 0, len(Dialects))
+	for n := range Dialects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Dialect{}, fmt.Errorf("unknown dialect %q, choose one of %s", name, strings.Join(names, ", "))
+}
+
+// Decode reads a mailbox value as an instruction of the dialect. The zero
+// Dialect decodes like Decode
+func (d Dialect) Decode(r Register) Instruction {
+	for opcode, code := range d.Extensions {
+		if r == code {
+			return Instruction{Opcode: opcode}
+		}
+	}
+	return Decode(r)
+}
@@ -1,11 +1,247 @@
 // Package models has all the models
 package models
 
+import (
+	"fmt"
+	"sort"
+)
+
 // Register ...
 type Register int
 
 // RAM ...
 type RAM map[int]Register
 
+// SortedAddresses returns the addresses held in RAM from lowest to highest.
+// Ranging over the map directly visits them in a different order every time
+func (ram RAM) SortedAddresses() []int {
+	addresses := make([]int, 0, len(ram))
+	for address := range ram {
+		addresses = append(addresses, address)
+	}
+	sort.Ints(addresses)
+	return addresses
+}
+
+// Each calls fn for every mailbox held in RAM in address order
+func (ram RAM) Each(fn func(address int, value Register)) {
+	for _, address := range ram.SortedAddresses() {
+		fn(address, ram[address])
+	}
+}
+
+// Equal reports whether both hold the same value in every mailbox. A mailbox
+// missing from one counts as holding 0, as it does in a CPU's memory
+func (ram RAM) Equal(other RAM) bool {
+	for address, value := range ram {
+		if other[address] != value {
+			return false
+		}
+	}
+	for address, value := range other {
+		if ram[address] != value {
+			return false
+		}
+	}
+	return true
+}
+
 // Opcode is a string but calling it opcode will make code easier to understand
 type Opcode string
+
+// MaxSize is the largest number of mailboxes a program can address. Operands
+// are the last two digits of a three digit instruction so they only reach 00-99.
+// Smaller memories are allowed for classroom variants of the machine
+const MaxSize = 100
+
+// The instruction set. DAT is not a real instruction, it marks a mailbox that holds data
+const (
+	HLT Opcode = "HLT"
+	ADD Opcode = "ADD"
+	SUB Opcode = "SUB"
+	STA Opcode = "STA"
+	LDA Opcode = "LDA"
+	BRA Opcode = "BRA"
+	BRZ Opcode = "BRZ"
+	BRP Opcode = "BRP"
+	INP Opcode = "INP"
+	OUT Opcode = "OUT"
+	DAT Opcode = "DAT"
+)
+
+// Opcodes maps each instruction to its machine code. Instructions that address
+// a mailbox add the mailbox number to this value
+var Opcodes = map[Opcode]Register{
+	HLT: 0,
+	ADD: 100,
+	SUB: 200,
+	STA: 300,
+	LDA: 500,
+	BRA: 600,
+	BRZ: 700,
+	BRP: 800,
+	INP: 901,
+	OUT: 902,
+}
+
+// HasOperand reports whether the opcode takes a mailbox operand
+func (o Opcode) HasOperand() bool {
+	switch o {
+	case ADD, SUB, STA, LDA, BRA, BRZ, BRP, DAT:
+		return true
+	}
+	return false
+}
+
+// descriptions say in a line what each instruction does
+var descriptions = map[Opcode]string{
+	HLT: "stop the program",
+	ADD: "add the value in a mailbox to the accumulator",
+	SUB: "subtract the value in a mailbox from the accumulator",
+	STA: "store the accumulator in a mailbox",
+	LDA: "load the value in a mailbox into the accumulator",
+	BRA: "branch to a mailbox",
+	BRZ: "branch to a mailbox if the accumulator is zero",
+	BRP: "branch to a mailbox if the accumulator is zero or more",
+	INP: "read a value from the in tray into the accumulator",
+	OUT: "send the accumulator to the out tray",
+	DAT: "reserve a mailbox for data, holding the value given or 0",
+	OTC: "send the accumulator to the out tray as a character",
+}
+
+// Description says in a line what the instruction does
+func (o Opcode) Description() string {
+	return descriptions[o]
+}
+
+// Instruction is a mailbox value split into what the little man reads from it
+type Instruction struct {
+	Opcode  Opcode
+	Operand int
+}
+
+func (i Instruction) String() string {
+	if i.Opcode.HasOperand() {
+		return fmt.Sprintf("%s %d", i.Opcode, i.Operand)
+	}
+	return string(i.Opcode)
+}
+
+// Decode reads a mailbox value as an instruction. Values that are not an
+// instruction decode as DAT with the whole value as the operand
+func Decode(r Register) Instruction {
+	switch {
+	case r >= 0 && r < 100:
+		return Instruction{Opcode: HLT, Operand: int(r)}
+	case r == Opcodes[INP]:
+		return Instruction{Opcode: INP}
+	case r == Opcodes[OUT]:
+		return Instruction{Opcode: OUT}
+	}
+
+	for opcode, code := range Opcodes {
+		if opcode.HasOperand() && r >= code && r < code+100 {
+			return Instruction{Opcode: opcode, Operand: int(r - code)}
+		}
+	}
+	return Instruction{Opcode: DAT, Operand: int(r)}
+}
+
+// Encode turns the instruction back into a mailbox value. The value always
+// decodes back to the same instruction: one that would not, such as ADD 150
+// reading back as SUB 50 or STA 601 landing on an I/O code, is a bug in the
+// caller and Encode panics. It is for instructions that are valid by
+// construction, such as those from Decode; use EncodeChecked for anything
+// built from input
+func (i Instruction) Encode() Register {
+	value, err := i.EncodeChecked()
+	if err != nil {
+		panic("models: " + err.Error())
+	}
+	return value
+}
+
+// EncodeChecked is Encode that returns the error from Check instead of
+// panicking
+func (i Instruction) EncodeChecked() (Register, error) {
+	if err := i.Check(); err != nil {
+		return 0, err
+	}
+	return i.value(), nil
+}
+
+// value is the mailbox value of the instruction, unchecked
+func (i Instruction) value() Register {
+	if i.Opcode == DAT {
+		return Register(i.Operand)
+	}
+	return Opcodes[i.Opcode] + Register(i.Operand)
+}
+
+// Check reports an instruction whose mailbox value would decode as something
+// else, such as ADD 150 becoming SUB 50 or an operand turning STA into INP.
+// Only INP and OUT encode to 9xx and only HLT to 0xx. DAT holds any value from
+// 0 to 999 and is only range checked
+func (i Instruction) Check() error {
+	value := i.value()
+	if value < 0 || value > 999 {
+		return fmt.Errorf("%s encodes to %d, outside 000-999", i, value)
+	}
+	if i.Opcode == DAT {
+		return nil
+	}
+	if _, ok := Opcodes[i.Opcode]; !ok {
+		return fmt.Errorf("unknown opcode %s", i.Opcode)
+	}
+	if decoded := Decode(value); decoded != i {
+		return fmt.Errorf("%s encodes to %03d, which decodes as %s", i, value, decoded)
+	}
+	return nil
+}
+
+// SourceLine records where in the assembly source a mailbox came from
+type SourceLine struct {
+	// File is the included file the line is from, "" for the file being
+	// assembled
+	File string
+	Line int
+	// Col is the column the mnemonic or machine code starts in, from 1
+	Col  int
+	Text string
+	// Operand is the operand as written, a number or a label
+	Operand string
+	Data    bool
+	// Macro is set on mailboxes a CALL or RET expanded into
+	Macro bool
+}
+
+// Program is assembled RAM together with the debug info the assembler collected
+type Program struct {
+	RAM    RAM
+	Size   int
+	Labels map[string]int
+	// Constants are the names defined with EQU. They stand for a value and
+	// do not take up a mailbox
+	Constants map[string]int
+	Source    map[int]SourceLine
+	// Title and Author come from @title and @author directives in the
+	// comments at the top of the source. They are "" when not given
+	Title  string
+	Author string
+	// Dialect is the instruction set the program was assembled for, to
+	// decode it with
+	Dialect Dialect
+}
+
+// Label returns the label naming a mailbox, or "" when it has none. When
+// several labels share the mailbox the alphabetically first is used so the
+// answer is always the same
+func (p *Program) Label(address int) string {
+	name := ""
+	for label, labelAddress := range p.Labels {
+		if labelAddress == address && (name == "" || label < name) {
+			name = label
+		}
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+package models
+
+import "testing"
+
+func TestInstructionCheck(t *testing.T) {
+	tests := []struct {
+		instruction Instruction
+		value       Register
+		ok          bool
+	}{
+		{Instruction{HLT, 0}, 0, true},
+		{Instruction{HLT, 99}, 99, true},
+		{Instruction{HLT, 100}, 0, false},
+
+		{Instruction{ADD, 0}, 100, true},
+		{Instruction{ADD, 99}, 199, true},
+		{Instruction{ADD, 100}, 0, false},
+		{Instruction{ADD, 150}, 0, false},
+		{Instruction{ADD, -1}, 0, false},
+
+		{Instruction{SUB, 0}, 200, true},
+		{Instruction{SUB, 99}, 299, true},
+		{Instruction{SUB, 100}, 0, false},
+
+		{Instruction{STA, 0}, 300, true},
+		{Instruction{STA, 99}, 399, true},
+		{Instruction{STA, 100}, 0, false},
+		{Instruction{STA, 601}, 0, false},
+
+		{Instruction{LDA, 0}, 500, true},
+		{Instruction{LDA, 99}, 599, true},
+		{Instruction{LDA, 100}, 0, false},
+
+		{Instruction{BRA, 0}, 600, true},
+		{Instruction{BRA, 99}, 699, true},
+		{Instruction{BRA, 100}, 0, false},
+
+		{Instruction{BRZ, 0}, 700, true},
+		{Instruction{BRZ, 99}, 799, true},
+		{Instruction{BRZ, 100}, 0, false},
+
+		{Instruction{BRP, 0}, 800, true},
+		{Instruction{BRP, 99}, 899, true},
+		{Instruction{BRP, 100}, 0, false},
+		{Instruction{BRP, 101}, 0, false},
+		{Instruction{BRP, 102}, 0, false},
+
+		{Instruction{INP, 0}, 901, true},
+		{Instruction{INP, 1}, 0, false},
+		{Instruction{OUT, 0}, 902, true},
+		{Instruction{OUT, 1}, 0, false},
+
+		{Instruction{DAT, 0}, 0, true},
+		{Instruction{DAT, 999}, 999, true},
+		{Instruction{DAT, 1000}, 0, false},
+		{Instruction{DAT, -1}, 0, false},
+
+		{Instruction{OTC, 0}, 0, false},
+	}
+
+	for _, test := range tests {
+		err := test.instruction.Check()
+		if (err == nil) != test.ok {
+			t.Errorf("%s: Check() = %v, want ok %v", test.instruction, err, test.ok)
+			continue
+		}
+		value, encodeErr := test.instruction.EncodeChecked()
+		if (encodeErr == nil) != test.ok {
+			t.Errorf("%s: EncodeChecked() error %v, want ok %v", test.instruction, encodeErr, test.ok)
+		}
+		if test.ok {
+			if value != test.value {
+				t.Errorf("%s: EncodeChecked() = %03d, want %03d", test.instruction, value, test.value)
+			}
+			if value := test.instruction.Encode(); value != test.value {
+				t.Errorf("%s: Encode() = %03d, want %03d", test.instruction, value, test.value)
+			}
+		}
+	}
+}
+
+func TestEncodePanicsOnBadInstruction(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Encode of ADD 150 did not panic")
+		}
+	}()
+	Instruction{ADD, 150}.Encode()
+}
+
+func TestDecodeEncodeRoundTrip(t *testing.T) {
+	for value := Register(0); value <= 999; value++ {
+		instruction := Decode(value)
+		if got := instruction.Encode(); got != value {
+			t.Errorf("%03d decodes as %s, which encodes to %03d", value, instruction, got)
+		}
+
+		// Only INP and OUT are 9xx instructions, everything else there is data
+		if value >= 900 {
+			want := DAT
+			switch value {
+			case 901:
+				want = INP
+			case 902:
+				want = OUT
+			}
+			if instruction.Opcode != want {
+				t.Errorf("%03d decodes as %s, want %s", value, instruction, want)
+			}
+		}
+	}
+}
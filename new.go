@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// template is the program lmc new writes: the usual shape of an LMC program,
+// reading input, working on it, sending the result out and halting, with the
+// data it uses at the end
+const template = `; @title My program
+; @author Your name
+;
+; Reads two numbers and outputs their sum. Replace the middle section with
+; your own code.
+
+; Input: INP reads a value from the in tray into the accumulator and STA
+; stores the accumulator in a labelled mailbox so it can be used later
+        INP             ; read the first number
+        STA first       ; keep it in the mailbox labelled first
+        INP             ; read the second number
+        STA second      ; keep it in second
+
+; Process: LDA loads a mailbox into the accumulator, ADD and SUB add or
+; subtract a mailbox from it. BRA, BRZ and BRP branch to a label always,
+; when the accumulator is zero, or when it is zero or more
+        LDA first       ; accumulator = first
+        ADD second      ; accumulator = first + second
+        STA result      ; result = accumulator
+
+; Output: OUT sends the accumulator to the out tray
+        LDA result
+        OUT
+
+; Halt: HLT stops the program. Without it the little man would carry on
+; into the data below and run it as if it were code
+        HLT
+
+; Data: DAT reserves a mailbox, optionally with a starting value. Labels
+; let the code above refer to them by name
+first   DAT             ; the first number
+second  DAT             ; the second number
+result  DAT 0           ; their sum
+`
+
+// newProgram writes the template program to stdout, or to the file named
+// after the command. It will not overwrite an existing file
+func newProgram() {
+	if flag.NArg() == 0 {
+		fmt.Print(template)
+		return
+	}
+
+	path := flag.Arg(0)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(template); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %s\n", path)
+}
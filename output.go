@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// numberedOutput writes each line sent to it prefixed with its number, e.g.
+// "#1: 42", so that several OUT values can be told apart
+type numberedOutput struct {
+	w io.Writer
+	n int
+	// midLine is true while part of a line has been written without its newline
+	midLine bool
+}
+
+func (o *numberedOutput) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if !o.midLine {
+			o.n++
+			if _, err := fmt.Fprintf(o.w, "#%d: ", o.n); err != nil {
+				return written, err
+			}
+			o.midLine = true
+		}
+
+		end := len(p)
+		for i, c := range p {
+			if c == '\n' {
+				end = i + 1
+				o.midLine = false
+				break
+			}
+		}
+		n, err := o.w.Write(p[:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[end:]
+	}
+	return written, nil
+}
+
+// outputSink wraps where OUT values go with the presentation flags
+func outputSink(w io.Writer) io.Writer {
+	if w == nil || !*numberOutput {
+		return w
+	}
+	return &numberedOutput{w: w}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// hltWatch spots, for -warn-hlt-overwrite, a store over a mailbox the program
+// assembled as a HLT. Once its halt is gone the program usually runs on past
+// where it was meant to stop
+type hltWatch struct {
+	program *models.Program
+	warned  map[int]bool
+}
+
+func newHLTWatch(program *models.Program) *hltWatch {
+	return &hltWatch{program: program, warned: make(map[int]bool)}
+}
+
+// after warns when the step just executed stored something other than a halt
+// over one of the program's HLT instructions. It warns once per mailbox and
+// never stops the run
+func (h *hltWatch) after(step vm.StepResult, cpu *vm.CPU, w io.Writer) {
+	// CALL stores its return branch over the HLT that RET leaves on purpose
+	if step.Instruction.Opcode != models.STA || h.program.Source[step.PC].Macro {
+		return
+	}
+
+	address := step.Instruction.Operand
+	source, ok := h.program.Source[address]
+	if !ok || source.Data || models.Decode(h.program.RAM[address]).Opcode != models.HLT || h.warned[address] {
+		return
+	}
+	if models.Decode(cpu.RAM[address]).Opcode == models.HLT {
+		return
+	}
+	h.warned[address] = true
+
+	from := h.program.Source[step.PC]
+	warn(w, compiler.Warning{
+		File: from.File,
+		Line: from.Line,
+		Msg: fmt.Sprintf("cycle %d: STA overwrote the HLT in mailbox %d (line %d) with %03d, so the program may not stop there",
+			cpu.Cycles, address, source.Line, cpu.RAM[address]),
+	})
+}
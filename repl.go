@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/display"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+const replHelp = `Commands:
+  step [n]                execute the next n instructions (default 1)
+  next                    step over a loop: on a backward branch, run until the loop is left
+  continue                run until a breakpoint or HLT, or an OUT with break-on-output on
+  run-until-output v      run until OUT sends the value v
+  goto-cycle n            run to exactly cycle n, restarting the program if it is behind
+  break [addr]            toggle a breakpoint on a mailbox, or list the breakpoints
+  break-if [cond]         toggle a stop after any step leaving e.g. acc == 0 or mem[9] > 5 true, or list them
+  mem                     show the memory grid
+  set mem addr value      write a value into a mailbox and show what it now decodes as
+  list [n]                disassemble n mailboxes either side of the program counter (default 5)
+  explain on|off          describe each instruction in plain English as it is stepped
+  diff on|off             list the mailboxes and accumulator each step changes
+  break-on-output on|off  make continue stop after every OUT
+  regs                    show the program counter and accumulator
+  help                    show this help
+  quit                    leave the debugger`
+
+// repl is the step debugger, a prompt for running a program a few instructions at a time
+type repl struct {
+	cpu     *vm.CPU
+	program *models.Program
+	scanner *bufio.Scanner
+	out     io.Writer
+	// explain describes each instruction in plain English as it is stepped
+	explain bool
+	// diff lists the mailboxes each step changed
+	diff bool
+	// breakOnOutput stops continue after an OUT
+	breakOnOutput bool
+	// changed are the mailboxes the last command wrote to, for -color
+	changed map[int]bool
+	// intro says where the program is paused and how to get help before
+	// the first prompt
+	intro bool
+}
+
+// stepFile opens the step debugger on the -file program
+func stepFile() {
+	openDebugger("step", false)
+}
+
+// debugFile is the front door to the step debugger: the same session as
+// step, opened with a line saying where it is paused and how to get help
+func debugFile() {
+	openDebugger("debug", true)
+}
+
+// openDebugger assembles the -file program and starts command's debugger
+// session on it, paused before the first instruction. A program that does
+// not assemble exits with its errors before any prompt
+func openDebugger(command string, intro bool) {
+	requireFile(command)
+
+	program := compileFile()
+	scanner := bufio.NewScanner(os.Stdin)
+	cpu := newCPU(program)
+	cpu.Output = outputSink(os.Stdout)
+	cpu.Input = newInput(scanner)
+
+	r := &repl{cpu: cpu, program: program, scanner: scanner, out: os.Stdout, diff: *diff, breakOnOutput: *breakOnOutput, intro: intro}
+	r.loop()
+}
+
+// loop reads commands until quit or the end of stdin
+func (r *repl) loop() {
+	display.PrintTitle(r.out, r.program)
+	r.showMemory()
+	display.PrintStatus(r.out, r.cpu)
+	if r.intro {
+		fmt.Fprintf(r.out, "Paused before %s in mailbox %02d. Type help for the commands, step to run it\n", r.cpu.Dialect.Decode(r.cpu.RAM[r.cpu.PC]), r.cpu.PC)
+	}
+
+	for {
+		fmt.Fprint(r.out, "(lmc) ")
+		if !r.scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return
+		}
+
+		fields := strings.Fields(r.scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !r.execute(strings.ToLower(fields[0]), fields[1:]) {
+			return
+		}
+	}
+}
+
+// execute runs one debugger command. It reports false when the debugger should exit
+func (r *repl) execute(command string, args []string) bool {
+	switch command {
+	case "step", "s":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				fmt.Fprintf(r.out, "step needs a positive count, not %q\n", args[0])
+				return true
+			}
+		}
+		r.step(n)
+	case "next", "n":
+		r.next()
+	case "continue", "c":
+		r.cont()
+	case "run-until-output":
+		r.runUntilOutput(args)
+	case "goto-cycle", "g":
+		r.gotoCycle(args)
+	case "break", "b":
+		r.toggleBreakpoint(args)
+	case "break-if":
+		r.toggleCondition(args)
+	case "mem", "m":
+		r.showMemory()
+	case "set":
+		r.set(args)
+	case "list", "l":
+		r.list(args)
+	case "explain":
+		r.toggle("explain", &r.explain, args)
+	case "diff":
+		r.toggle("diff", &r.diff, args)
+	case "break-on-output":
+		r.toggle("break-on-output", &r.breakOnOutput, args)
+	case "regs", "r":
+		display.PrintStatus(r.out, r.cpu)
+	case "help", "h", "?":
+		fmt.Fprintln(r.out, replHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Fprintf(r.out, "unknown command %q, type help for a list\n", command)
+	}
+	return true
+}
+
+// cont runs until a breakpoint or HLT, or with break-on-output on until the
+// next OUT has sent its value
+func (r *repl) cont() {
+	sent := len(r.cpu.Outputs())
+	err := r.run(func(c *vm.CPU) bool {
+		return r.breakOnOutput && len(c.Outputs()) > sent
+	})
+	if outputs := r.cpu.Outputs(); err == nil && len(outputs) > sent && r.breakOnOutput {
+		fmt.Fprintf(r.out, "stopped after OUT sent %d\n", outputs[len(outputs)-1])
+	}
+	r.report(err)
+}
+
+// runUntilOutput runs until OUT sends the given value, stopping just after
+// it, or until a breakpoint or HLT says otherwise
+func (r *repl) runUntilOutput(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(r.out, "run-until-output needs a value")
+		return
+	}
+	target, err := vm.ParseValue(args[0])
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+
+	seen := len(r.cpu.Outputs())
+	found := false
+	err = r.run(func(c *vm.CPU) bool {
+		outputs := c.Outputs()
+		if len(outputs) == seen {
+			return false
+		}
+		seen = len(outputs)
+		found = outputs[seen-1] == target
+		return found
+	})
+
+	switch {
+	case found:
+		fmt.Fprintf(r.out, "OUT sent %d at cycle %d\n", target, r.cpu.Cycles)
+	case err == nil && r.cpu.Halted:
+		fmt.Fprintf(r.out, "the program halted without sending %d\n", target)
+	}
+	r.report(err)
+}
+
+// next steps over the loop when the current instruction branches backwards,
+// running until the program counter leaves the mailboxes between the branch
+// target and the branch. Anything else is a single step
+func (r *repl) next() {
+	instruction := r.cpu.Dialect.Decode(r.cpu.RAM[r.cpu.PC])
+	switch instruction.Opcode {
+	case models.BRA, models.BRZ, models.BRP:
+		if start, end := instruction.Operand, r.cpu.PC; start <= end {
+			r.report(r.run(func(c *vm.CPU) bool {
+				return c.PC < start || c.PC > end
+			}))
+			return
+		}
+	}
+
+	r.step(1)
+}
+
+// gotoCycle fast-forwards to the moment the given number of instructions have
+// executed, passing any breakpoints on the way. A cycle already behind is
+// reached by restarting the program with the input it has read so far
+func (r *repl) gotoCycle(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(r.out, "goto-cycle needs a cycle number")
+		return
+	}
+	target, err := strconv.Atoi(args[0])
+	if err != nil || target < 0 {
+		fmt.Fprintf(r.out, "goto-cycle needs a cycle number, not %q\n", args[0])
+		return
+	}
+
+	if target < r.cpu.Cycles {
+		r.restart()
+		fmt.Fprintln(r.out, "restarted the program")
+	}
+
+	start := r.cpu.Snapshot()
+	defer func() { r.changed = changedMailboxes(vm.Diff(start, r.cpu.Snapshot())) }()
+	for r.cpu.Cycles < target && !r.cpu.Halted {
+		if _, err := r.cpu.StepN(target - r.cpu.Cycles); err != nil {
+			r.report(err)
+			return
+		}
+	}
+
+	if r.cpu.Cycles < target {
+		fmt.Fprintf(r.out, "error: the program halted at cycle %d, before cycle %d\n", r.cpu.Cycles, target)
+	}
+	r.report(nil)
+}
+
+// restart loads the program into a fresh CPU, keeping the breakpoints, the
+// break conditions and where input and output go. The values INP has read
+// so far are read again in the same order, so the run retraces the one the
+// user saw, and only then does input carry on from where it had got to
+func (r *repl) restart() {
+	var values vm.Values
+	for _, event := range r.cpu.IOLog() {
+		if event.Kind == vm.EventIn {
+			values = append(values, event.Value)
+		}
+	}
+
+	cpu := newCPU(r.program)
+	cpu.Output = r.cpu.Output
+	cpu.Input = &replayedInput{values: values, rest: r.cpu.Input}
+	cpu.Breakpoints = r.cpu.Breakpoints
+	cpu.BreakConditions = r.cpu.BreakConditions
+	r.cpu = cpu
+}
+
+// replayedInput reads values already read once before going on to rest
+type replayedInput struct {
+	values vm.Values
+	rest   vm.Input
+}
+
+func (in *replayedInput) Read(mailbox int) (int, error) {
+	if len(in.values) > 0 {
+		return in.values.Read(mailbox)
+	}
+	if in.rest == nil {
+		return 0, vm.ErrInputExhausted
+	}
+	return in.rest.Read(mailbox)
+}
+
+// step executes n instructions, printing a line of trace for each. With
+// explain on every instruction is described before it runs, and with diff on
+// the mailboxes it changed are listed after
+func (r *repl) step(n int) {
+	start := r.cpu.Snapshot()
+	defer func() { r.changed = changedMailboxes(vm.Diff(start, r.cpu.Snapshot())) }()
+
+	for i := 0; i < n && !r.cpu.Halted; i++ {
+		if r.explain {
+			fmt.Fprintln(r.out, vm.Explain(r.cpu.Dialect.Decode(r.cpu.RAM[r.cpu.PC]), r.cpu))
+		}
+
+		before := r.cpu.Snapshot()
+		steps, err := r.cpu.StepN(1)
+		for _, step := range steps {
+			display.PrintStep(r.out, step)
+		}
+		if r.diff {
+			display.PrintChanges(r.out, r.cpu.Cycles, vm.Diff(before, r.cpu.Snapshot()))
+		}
+
+		if err != nil || r.cpu.AtBreak() {
+			r.report(err)
+			return
+		}
+	}
+	r.report(nil)
+}
+
+// list disassembles the mailboxes around the program counter, marking the
+// instruction that runs next with an arrow
+func (r *repl) list(args []string) {
+	window := 5
+	if len(args) > 0 {
+		var err error
+		if window, err = strconv.Atoi(args[0]); err != nil || window < 0 {
+			fmt.Fprintf(r.out, "list needs a window size, not %q\n", args[0])
+			return
+		}
+	}
+
+	for address := r.cpu.PC - window; address <= r.cpu.PC+window; address++ {
+		if address < 0 || address >= r.cpu.Size {
+			continue
+		}
+
+		marker := "  "
+		if address == r.cpu.PC {
+			marker = "->"
+		}
+		line := compiler.DisassembleMailbox(r.cpu.RAM, address, r.program)
+		fmt.Fprintf(r.out, "%s %02d  %03d  %s\n", marker, address, line.Value, line)
+	}
+}
+
+// run runs until a breakpoint, HLT or stop says so. Ctrl-C interrupts it and
+// comes back to the debugger prompt instead of quitting
+func (r *repl) run(stop func(*vm.CPU) bool) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	start := r.cpu.Snapshot()
+	err := r.cpu.RunUntilContext(ctx, stop)
+	r.changed = changedMailboxes(vm.Diff(start, r.cpu.Snapshot()))
+	return err
+}
+
+// showMemory prints the memory grid, highlighting the program counter and
+// the mailboxes the last command changed
+func (r *repl) showMemory() {
+	showMemory(r.out, r.cpu.RAM, r.cpu.Size, display.Highlight{PC: r.cpu.PC, Changed: r.changed, Program: r.program})
+}
+
+// report shows where execution stopped after a step or continue
+func (r *repl) report(err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(r.out, "interrupted")
+	case err != nil:
+		fmt.Fprintf(r.out, "error: %v\n", err)
+	}
+	display.PrintStatus(r.out, r.cpu)
+	if !r.cpu.Halted && r.cpu.Breakpoints[r.cpu.PC] {
+		fmt.Fprintf(r.out, "breakpoint at mailbox %d\n", r.cpu.PC)
+	}
+	if condition, ok := r.cpu.BreakCondition(); ok && !r.cpu.Halted {
+		fmt.Fprintf(r.out, "stopped with %s\n", condition)
+	}
+}
+
+// set changes the machine. set mem writes a mailbox and disassembles it
+// before and after, so an edited instruction can be checked at a glance
+func (r *repl) set(args []string) {
+	if len(args) != 3 || strings.ToLower(args[0]) != "mem" {
+		fmt.Fprintln(r.out, "set needs a mailbox and a value, e.g. set mem 5 501")
+		return
+	}
+
+	address, err := strconv.Atoi(args[1])
+	if err != nil || address < 0 || address >= r.cpu.Size {
+		fmt.Fprintf(r.out, "%q is not a mailbox from 0 to %d\n", args[1], r.cpu.Size-1)
+		return
+	}
+	value, err := vm.ParseValue(args[2])
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+
+	before := compiler.DisassembleMailbox(r.cpu.RAM, address, r.program)
+	r.cpu.RAM[address] = models.Register(value)
+	after := compiler.DisassembleMailbox(r.cpu.RAM, address, r.program)
+	r.changed = map[int]bool{address: true}
+	fmt.Fprintf(r.out, "mailbox %02d: %03d %s -> %03d %s\n", address, before.Value, before.Instruction(), after.Value, after.Instruction())
+}
+
+// toggle turns a debugger setting on or off, flipping it when neither is given
+func (r *repl) toggle(name string, setting *bool, args []string) {
+	if len(args) == 0 {
+		args = []string{"on"}
+		if *setting {
+			args[0] = "off"
+		}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		*setting = true
+	case "off":
+		*setting = false
+	default:
+		fmt.Fprintf(r.out, "%s takes on or off, not %q\n", name, args[0])
+		return
+	}
+	fmt.Fprintf(r.out, "%s %s\n", name, strings.ToLower(args[0]))
+}
+
+// toggleCondition adds a break condition, or removes it when it is already
+// set, or lists them when none is given. Conditions are compared as written
+// out by Condition.String, so acc==0 clears acc == 0
+func (r *repl) toggleCondition(args []string) {
+	if len(args) == 0 {
+		for _, condition := range r.cpu.BreakConditions {
+			fmt.Fprintf(r.out, "break if %s\n", condition)
+		}
+		return
+	}
+
+	condition, err := vm.ParseCondition(strings.Join(args, " "), r.cpu.Size)
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+	for i, set := range r.cpu.BreakConditions {
+		if set.String() == condition.String() {
+			r.cpu.BreakConditions = append(r.cpu.BreakConditions[:i], r.cpu.BreakConditions[i+1:]...)
+			fmt.Fprintf(r.out, "cleared break if %s\n", condition)
+			return
+		}
+	}
+	r.cpu.BreakConditions = append(r.cpu.BreakConditions, condition)
+	fmt.Fprintf(r.out, "break if %s\n", condition)
+}
+
+// toggleBreakpoint sets or clears a breakpoint, or lists them when no mailbox is given
+func (r *repl) toggleBreakpoint(args []string) {
+	if len(args) == 0 {
+		for address := 0; address < r.cpu.Size; address++ {
+			if r.cpu.Breakpoints[address] {
+				fmt.Fprintf(r.out, "breakpoint at mailbox %d\n", address)
+			}
+		}
+		return
+	}
+
+	address, err := strconv.Atoi(args[0])
+	if err != nil || address < 0 || address >= r.cpu.Size {
+		fmt.Fprintf(r.out, "%q is not a mailbox from 0 to %d\n", args[0], r.cpu.Size-1)
+		return
+	}
+	if r.cpu.Breakpoints[address] {
+		delete(r.cpu.Breakpoints, address)
+		fmt.Fprintf(r.out, "cleared breakpoint at mailbox %d\n", address)
+		return
+	}
+	r.cpu.Breakpoints[address] = true
+	fmt.Fprintf(r.out, "breakpoint at mailbox %d\n", address)
+}
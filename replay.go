@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/display"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// replayTrace plays back a trace written by run -trace-json, showing every
+// recorded step and what it changed. Nothing is executed, so the program does
+// not need to be at hand
+func replayTrace() {
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "replay needs a trace: lmc replay trace.jsonl")
+		os.Exit(1)
+	}
+	path := flag.Arg(0)
+	// Flags stop at the trace file, so pick up any written after it
+	flag.CommandLine.Parse(flag.Args()[1:])
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	records, err := vm.ReadTrace(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	var previous vm.Snapshot
+	for i, record := range records {
+		if i > 0 && *stepDelay > 0 {
+			time.Sleep(*stepDelay)
+		}
+
+		current := snapshotOf(record)
+		display.PrintStep(os.Stdout, vm.StepResult{
+			PC:          record.PC,
+			Value:       record.Value,
+			Instruction: record.Decode(),
+			Accumulator: record.Accumulator,
+			Negative:    record.Negative,
+			Halted:      record.Halted,
+		})
+		if record.Output != nil {
+			fmt.Printf("    OUT %d\n", *record.Output)
+		}
+		// The first record has nothing before it to compare with
+		if i > 0 {
+			display.PrintChanges(os.Stdout, record.Cycle, vm.Diff(previous, current))
+		}
+		previous = current
+	}
+
+	last := records[len(records)-1]
+	cpu := &vm.CPU{
+		RAM:         previous.RAM,
+		PC:          last.PC,
+		Accumulator: last.Accumulator,
+		Negative:    last.Negative,
+		Halted:      last.Halted,
+		Cycles:      last.Cycle,
+		Cost:        last.Cycle,
+		Size:        len(last.Memory),
+	}
+	fmt.Println()
+	var changed map[int]bool
+	if len(records) > 1 {
+		changed = changedMailboxes(vm.Diff(snapshotOf(records[len(records)-2]), previous))
+	}
+	showMemory(os.Stdout, cpu.RAM, cpu.Size, display.Highlight{PC: cpu.PC, Changed: changed})
+	display.PrintStatus(os.Stdout, cpu)
+}
+
+// snapshotOf is the memory and accumulator a trace record holds
+func snapshotOf(record vm.TraceRecord) vm.Snapshot {
+	snapshot := vm.Snapshot{RAM: make(models.RAM), Accumulator: record.Accumulator, Negative: record.Negative}
+	for address, value := range record.Memory {
+		snapshot.RAM[address] = value
+	}
+	return snapshot
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// runReport is everything -report says about a run, in one place for a
+// grader to keep
+type runReport struct {
+	File   string `json:"file"`
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+
+	Outputs     []int `json:"outputs"`
+	Accumulator int   `json:"accumulator"`
+	Signed      int   `json:"signedAccumulator"`
+	Cycles      int   `json:"cycles"`
+	// Instructions is how many instructions ran. Cycles counts the same
+	// unless a cycle model weights them
+	Instructions int    `json:"instructions"`
+	Halted       bool   `json:"halted"`
+	Error        string `json:"error,omitempty"`
+
+	Coverage coverage     `json:"coverage"`
+	IO       []vm.IOEvent `json:"io"`
+	Warnings []string     `json:"warnings"`
+}
+
+// coverage is how much of the program's code the run executed
+type coverage struct {
+	Executed int `json:"executed"`
+	Total    int `json:"total"`
+	// Missed are the source lines of the code cells that never ran
+	Missed []int `json:"missed"`
+}
+
+// newRunReport gathers the report of a run that ended with err
+func newRunReport(program *models.Program, cpu *vm.CPU, err error) runReport {
+	result := cpu.Result(err)
+	report := runReport{
+		File:         *file,
+		Title:        program.Title,
+		Author:       program.Author,
+		Outputs:      result.Outputs,
+		Accumulator:  result.Accumulator,
+		Signed:       result.Signed,
+		Cycles:       result.Cycles,
+		Instructions: result.Instructions,
+		Halted:       result.Halted,
+		Error:        result.Error,
+		IO:           result.IO,
+		Warnings:     printedWarnings,
+		Coverage:     coverage{Missed: []int{}},
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+
+	executed := cpu.Executed()
+	missed := make(map[int]bool)
+	for address := 0; address < program.Size; address++ {
+		source, ok := program.Source[address]
+		if !ok || source.Data {
+			continue
+		}
+		report.Coverage.Total++
+		if executed[address] > 0 {
+			report.Coverage.Executed++
+		} else if !missed[source.Line] {
+			// Lines from included files are not told apart from the main file
+			missed[source.Line] = true
+			report.Coverage.Missed = append(report.Coverage.Missed, source.Line)
+		}
+	}
+	return report
+}
+
+// print writes the report as a block of text
+func (r runReport) print(w io.Writer) {
+	heading := "Report for " + r.File
+	if r.Title != "" {
+		heading += ": " + r.Title
+	}
+	if r.Author != "" {
+		heading += " by " + r.Author
+	}
+	fmt.Fprintln(w, heading)
+
+	outputs := make([]string, len(r.Outputs))
+	for i, value := range r.Outputs {
+		outputs[i] = fmt.Sprint(value)
+	}
+	fmt.Fprintf(w, "  Outputs:      %s\n", orNone(strings.Join(outputs, ", ")))
+
+	acc := fmt.Sprintf("%03d", r.Accumulator)
+	if r.Signed != r.Accumulator {
+		acc = fmt.Sprintf("%d (%03d)", r.Signed, r.Accumulator)
+	}
+	fmt.Fprintf(w, "  Accumulator:  %s\n", acc)
+
+	cycles := fmt.Sprint(r.Cycles)
+	if r.Instructions != r.Cycles {
+		cycles += fmt.Sprintf(" (%d instructions)", r.Instructions)
+	}
+	fmt.Fprintf(w, "  Cycles:       %s\n", cycles)
+
+	status := "halted"
+	switch {
+	case r.Error != "":
+		status = "error: " + r.Error
+	case !r.Halted:
+		status = "stopped before HLT"
+	}
+	fmt.Fprintf(w, "  Status:       %s\n", status)
+
+	percent := 100
+	if r.Coverage.Total > 0 {
+		percent = r.Coverage.Executed * 100 / r.Coverage.Total
+	}
+	fmt.Fprintf(w, "  Coverage:     %d of %d instructions run (%d%%)\n", r.Coverage.Executed, r.Coverage.Total, percent)
+	if len(r.Coverage.Missed) > 0 {
+		lines := make([]string, len(r.Coverage.Missed))
+		for i, line := range r.Coverage.Missed {
+			lines[i] = fmt.Sprint(line)
+		}
+		fmt.Fprintf(w, "  Never run:    line %s\n", strings.Join(lines, ", "))
+	}
+
+	io := make([]string, len(r.IO))
+	for i, event := range r.IO {
+		io[i] = fmt.Sprintf("%s %d", event.Kind, event.Value)
+	}
+	fmt.Fprintf(w, "  I/O:          %s\n", orNone(strings.Join(io, ", ")))
+
+	if len(r.Warnings) == 0 {
+		fmt.Fprintln(w, "  Warnings:     none")
+		return
+	}
+	fmt.Fprintln(w, "  Warnings:")
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "    %s\n", warning)
+	}
+}
+
+// orNone stands in "none" for an empty list
+func orNone(list string) string {
+	if list == "" {
+		return "none"
+	}
+	return list
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/display"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+const shellHelp = `Type assembly one line at a time. After each line the program so far is
+assembled and the memory grid shown. Lines starting with a dot are commands:
+  .run    run the program entered so far
+  .undo   drop the last line
+  .list   show the lines entered so far
+  .clear  start again with an empty program
+  .help   show this help
+  .quit   leave`
+
+// shell is the interactive assembler, a prompt that assembles the program as
+// it is typed in and runs it on request
+type shell struct {
+	lines   []string
+	scanner *bufio.Scanner
+	out     io.Writer
+	// program is the last version of lines that assembled. It stays in place
+	// while a line is waiting on a label defined further down
+	program *models.Program
+}
+
+// startShell opens the interactive assembler on the terminal
+func startShell() {
+	s := &shell{scanner: bufio.NewScanner(os.Stdin), out: os.Stdout}
+	fmt.Fprintln(s.out, shellHelp)
+	s.loop()
+}
+
+// loop reads lines until .quit or the end of stdin
+func (s *shell) loop() {
+	for {
+		fmt.Fprintf(s.out, "%02d> ", len(s.lines)+1)
+		if !s.scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return
+		}
+
+		line := s.scanner.Text()
+		if command := strings.TrimSpace(line); strings.HasPrefix(command, ".") {
+			if !s.execute(strings.ToLower(command)) {
+				return
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		s.lines = append(s.lines, line)
+		s.assemble()
+	}
+}
+
+// execute runs one dot command. It reports false when the shell should exit
+func (s *shell) execute(command string) bool {
+	switch command {
+	case ".run", ".r":
+		s.run()
+	case ".undo", ".u":
+		if len(s.lines) == 0 {
+			fmt.Fprintln(s.out, "nothing to undo")
+			return true
+		}
+		fmt.Fprintf(s.out, "dropped %s\n", strings.TrimSpace(s.lines[len(s.lines)-1]))
+		s.lines = s.lines[:len(s.lines)-1]
+		s.assemble()
+	case ".list", ".l":
+		for i, line := range s.lines {
+			fmt.Fprintf(s.out, "%02d  %s\n", i+1, line)
+		}
+	case ".clear":
+		s.lines, s.program = nil, nil
+		fmt.Fprintln(s.out, "cleared")
+	case ".help", ".h":
+		fmt.Fprintln(s.out, shellHelp)
+	case ".quit", ".q", ".exit":
+		return false
+	default:
+		fmt.Fprintf(s.out, "unknown command %q, type .help for a list\n", command)
+	}
+	return true
+}
+
+// assemble assembles the lines entered so far and shows the memory grid. A
+// program that does not assemble yet, often because a label it uses has not
+// been typed in, is reported and the last good grid shown instead
+func (s *shell) assemble() {
+	program, err := s.compile()
+	if err == nil {
+		s.program = program
+	}
+
+	if s.program != nil {
+		showMemory(s.out, s.program.RAM, s.program.Size, display.Highlight{PC: -1, Program: s.program})
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "not assembled: %v\n", err)
+	}
+}
+
+// compile assembles the lines entered so far
+func (s *shell) compile() (*models.Program, error) {
+	return compiler.Assemble(strings.NewReader(strings.Join(s.lines, "\n")), compiler.Options{Size: *size, Dialect: selectedDialect()})
+}
+
+// run executes what has been entered so far from a fresh CPU. Ctrl-C stops it
+// and comes back to the prompt
+func (s *shell) run() {
+	program, err := s.compile()
+	if err != nil {
+		fmt.Fprintf(s.out, "compile error: %v\n", err)
+		return
+	}
+
+	cpu := newCPU(program)
+	cpu.Output = outputSink(s.out)
+	cpu.Input = newInput(s.scanner)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	start := cpu.Snapshot()
+	err = cpu.RunContext(ctx)
+
+	showMemory(s.out, cpu.RAM, cpu.Size, display.Highlight{
+		PC:      cpu.PC,
+		Changed: changedMailboxes(vm.Diff(start, cpu.Snapshot())),
+		Program: program,
+	})
+	display.PrintStatus(s.out, cpu)
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(s.out, "interrupted")
+	case err != nil:
+		fmt.Fprintf(s.out, "error: %v\n", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// terminalWidth reports 0, unknown, where there is no way to ask the terminal
+func terminalWidth(f *os.File) int {
+	return 0
+}
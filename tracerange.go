@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// traceRanges are the windows of mailboxes given with -trace-range. -trace
+// only logs instructions whose address is inside one of them
+var traceRanges rangeList
+
+func init() {
+	flag.Var(&traceRanges, "trace-range", "Like -trace but only log instructions in these mailboxes, e.g. 5-12. Separate ranges with commas or repeat the flag")
+}
+
+// mailboxRange is a window of mailboxes from First to Last inclusive
+type mailboxRange struct {
+	First, Last int
+}
+
+// rangeList is a flag that collects mailbox ranges each time it is given
+type rangeList []mailboxRange
+
+func (r *rangeList) String() string {
+	ranges := make([]string, len(*r))
+	for i, window := range *r {
+		ranges[i] = fmt.Sprintf("%d-%d", window.First, window.Last)
+	}
+	return strings.Join(ranges, ",")
+}
+
+// Set adds the ranges in s, such as 5-12 or 5-12,40-45. A single mailbox
+// such as 7 is a range of one. Whether they are inside -size is checked
+// once the run starts
+func (r *rangeList) Set(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		from, err := rangeAddress(first)
+		if err != nil {
+			return err
+		}
+		to, err := rangeAddress(last)
+		if err != nil {
+			return err
+		}
+		if from > to {
+			return fmt.Errorf("range %s ends before it starts", part)
+		}
+		*r = append(*r, mailboxRange{First: from, Last: to})
+	}
+	return nil
+}
+
+// rangeAddress parses one end of a mailbox range
+func rangeAddress(s string) (int, error) {
+	address, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || address < 0 || address >= models.MaxSize {
+		return 0, fmt.Errorf("%q is not a mailbox from 0 to %d", s, models.MaxSize-1)
+	}
+	return address, nil
+}
+
+// traced reports whether -trace logs the instruction at address: always when
+// no -trace-range was given
+func traced(address int) bool {
+	if len(traceRanges) == 0 {
+		return true
+	}
+	for _, window := range traceRanges {
+		if address >= window.First && address <= window.Last {
+			return true
+		}
+	}
+	return false
+}
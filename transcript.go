@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/display"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// transcriptOut is the -transcript file while a run is being recorded, nil
+// otherwise. It is written to as the run happens and never buffered, so a run
+// stopped with Ctrl-C still leaves a record of everything up to that point
+var transcriptOut io.Writer
+
+// openTranscript starts the -transcript record of a run at path, for handing
+// in: the command line, the source with the mailbox of each line and the
+// warnings printed while assembling. teeTranscript and recordInput then copy
+// the run into it as it goes, and closeTranscript finishes it
+func openTranscript(path string, program *models.Program) (*os.File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(f, "$ lmc %s\n\n", strings.Join(os.Args[1:], " "))
+	display.PrintTitle(f, program)
+
+	fmt.Fprintf(f, "Program %s\n", *file)
+	source, err := os.Open(*file)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	err = compiler.Annotate(source, program, f)
+	source.Close()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, warning := range printedWarnings {
+		fmt.Fprintf(f, "warning: %s\n", warning)
+	}
+
+	if _, err := fmt.Fprintln(f, "\nRun"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	transcriptOut = f
+	return f, nil
+}
+
+// teeTranscript returns a writer that sends to w and, while a run is being
+// recorded, to the transcript too
+func teeTranscript(w io.Writer) io.Writer {
+	if transcriptOut == nil {
+		return w
+	}
+	if w == nil {
+		return transcriptOut
+	}
+	return io.MultiWriter(w, transcriptOut)
+}
+
+// recordedInput copies every value INP reads into the transcript. After a
+// prompt, which the transcript gets as well, the value finishes the prompt's
+// line as it does on the terminal
+type recordedInput struct {
+	in       vm.Input
+	prompted bool
+}
+
+func (r *recordedInput) Read(mailbox int) (int, error) {
+	value, err := r.in.Read(mailbox)
+	if err != nil {
+		return value, err
+	}
+	if r.prompted {
+		fmt.Fprintf(transcriptOut, "%d\n", value)
+	} else {
+		fmt.Fprintf(transcriptOut, "INP %d\n", value)
+	}
+	return value, nil
+}
+
+// recordInput wraps in so that the transcript gets the INP prompts and the
+// values read, whether typed or given with -input
+func recordInput(in vm.Input) vm.Input {
+	if transcriptOut == nil {
+		return in
+	}
+	prompted := false
+	if line, ok := in.(*vm.LineInput); ok {
+		if line.Prompt != nil {
+			line.Prompt = teeTranscript(line.Prompt)
+			prompted = true
+		}
+		if line.Echo != nil {
+			line.Echo = teeTranscript(line.Echo)
+		}
+	}
+	return &recordedInput{in: in, prompted: prompted}
+}
+
+// closeTranscript finishes the record of a run that ended with err with the
+// error and the final memory and registers
+func closeTranscript(f *os.File, cpu *vm.CPU, err error) error {
+	transcriptOut = nil
+	if err != nil {
+		fmt.Fprintf(f, "error: %v\n", err)
+	}
+
+	fmt.Fprintln(f, "\nFinal state")
+	display.PrintRegisters(f, cpu.RAM, cpu.Size, display.GridWidth)
+	display.PrintStatus(f, cpu)
+	return f.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// uninitWatch spots, for -warn-uninit, the first read of a data cell that was
+// declared with a bare DAT and has not been stored to since. Such a read gets
+// a zero the author probably did not mean to rely on
+type uninitWatch struct {
+	program *models.Program
+	// written are the mailboxes that have been written by STA or -seed-memory
+	written map[int]bool
+	warned  map[int]bool
+}
+
+// newUninitWatch starts watching a run of program. Mailboxes set by
+// -seed-memory count as written
+func newUninitWatch(program *models.Program, size int) *uninitWatch {
+	u := &uninitWatch{program: program, written: make(map[int]bool), warned: make(map[int]bool)}
+	if *seedMemory != "" {
+		// newCPU has already rejected seeds that do not parse
+		seeds, _ := parseSeeds(*seedMemory, size)
+		for address := range seeds {
+			u.written[address] = true
+		}
+	}
+	return u
+}
+
+// before warns when the instruction the CPU is about to execute reads a data
+// cell that has never been given a value
+func (u *uninitWatch) before(cpu *vm.CPU, w io.Writer) {
+	instruction := cpu.Dialect.Decode(cpu.RAM[cpu.PC])
+	switch instruction.Opcode {
+	case models.LDA, models.ADD, models.SUB:
+	default:
+		return
+	}
+
+	address := instruction.Operand
+	source, ok := u.program.Source[address]
+	if !ok || !source.Data || source.Operand != "" || u.written[address] || u.warned[address] {
+		return
+	}
+	u.warned[address] = true
+
+	name := fmt.Sprintf("mailbox %d", address)
+	if label := u.program.Label(address); label != "" {
+		name = fmt.Sprintf("'%s'", label)
+	}
+	source = u.program.Source[cpu.PC]
+	warn(w, compiler.Warning{
+		File: source.File,
+		Line: source.Line,
+		Msg:  fmt.Sprintf("%s reads %s before anything is stored in it", instruction.Opcode, name),
+	})
+}
+
+// after records the mailbox a step stored to
+func (u *uninitWatch) after(step vm.StepResult) {
+	if step.Instruction.Opcode == models.STA {
+		u.written[step.Instruction.Operand] = true
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+)
+
+// validateFile checks a program without running it. -file assembles the
+// source and -machine loads a compiled file of mailbox values. With -strict
+// warnings fail the check too
+func validateFile() {
+	switch {
+	case *machine != "":
+		validateMachineCode(*machine)
+	case *file != "":
+		compileFile()
+		fmt.Printf("%s: ok\n", *file)
+	default:
+		fmt.Fprintln(os.Stderr, "validate needs a program: lmc validate -file prog.lmc or lmc validate -machine prog.out")
+		os.Exit(1)
+	}
+}
+
+// validateMachineCode checks the mailbox values in the file at path
+func validateMachineCode(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	ram, warnings, err := compiler.ValidateMachineCode(f, *size)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s: %v\n", path, err)
+
+		var asmErr *compiler.Error
+		if errors.As(err, &asmErr) && asmErr.Underline() != "" {
+			fmt.Fprintln(os.Stderr, asmErr.Underline())
+		}
+		os.Exit(1)
+	}
+
+	for _, warning := range warnings {
+		warn(os.Stderr, fmt.Sprintf("%s: %v", path, warning))
+	}
+	if *strict && len(warnings) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok, %d values\n", path, len(ram))
+}
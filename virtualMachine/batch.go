@@ -0,0 +1,69 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// BatchMaxCycles is how many instructions each program in RunBatch may run
+// before it is stopped, so a submission stuck in a loop cannot hold up the
+// rest. It matches the default of the lmc -max-cycles flag
+const BatchMaxCycles = 1000000
+
+// RunBatch assembles and runs each of the programs with the INP values at the
+// same index of inputs, for grading many submissions at once. A program that
+// does not assemble, fails while running or runs out of cycles gets a result
+// with Error set and the rest of the batch carries on. Programs without
+// inputs read none. Submissions cannot #include files, so none of them can
+// read files from the machine grading them.
+//
+// Every run gets a CPU of its own and nothing is shared between them, so
+// RunBatch may be called from several goroutines at once, for example to
+// split a large batch across them
+func RunBatch(programs []string, inputs [][]int) []RunResult {
+	results := make([]RunResult, len(programs))
+	for i, source := range programs {
+		var values []int
+		if i < len(inputs) {
+			values = inputs[i]
+		}
+		results[i] = runOne(source, values)
+	}
+	return results
+}
+
+// runOne assembles and runs one program of a batch. A panic is reported as
+// the program's error rather than taking down the batch
+func runOne(source string, inputs []int) (result RunResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = failed(fmt.Errorf("internal error: %v", r))
+		}
+	}()
+
+	program, err := compiler.Assemble(strings.NewReader(source), compiler.Options{NoIncludes: true})
+	if err != nil {
+		return failed(err)
+	}
+
+	cpu := New(program.RAM)
+	cpu.Size = program.Size
+	cpu.Program = program
+	cpu.Dialect = program.Dialect
+	cpu.MaxCycles = BatchMaxCycles
+	values := make(Values, len(inputs))
+	copy(values, inputs)
+	cpu.Input = &values
+
+	result = cpu.Result(cpu.Run())
+	result.Title, result.Author = program.Title, program.Author
+	return result
+}
+
+// failed is the result of a program that never ran
+func failed(err error) RunResult {
+	return RunResult{Outputs: []int{}, IO: []IOEvent{}, Memory: []models.Register{}, Error: err.Error()}
+}
@@ -0,0 +1,138 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Condition is a test of the machine's state, such as acc == 0 or
+// mem[9] > 5, that a run can stop on. Each side is acc, pc, mem[n] or a
+// number. acc is the signed value, so acc < 0 is true after a SUB goes below
+// zero
+type Condition struct {
+	left, right term
+	op          string
+}
+
+// term is one side of a Condition
+type term struct {
+	// name is acc, pc or mem, or "" for a number
+	name string
+	// value is the number, or the mailbox of mem[n]
+	value int
+}
+
+// comparisons are the operators a Condition can use. Two character ones come
+// first so that <= is not read as <
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// ParseCondition parses a condition such as acc == 0 or mem[9]>5. Mailboxes
+// must exist in a memory of size mailboxes
+func ParseCondition(s string, size int) (Condition, error) {
+	for _, op := range comparisons {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+		left, err := parseTerm(strings.TrimSpace(s[:i]), size)
+		if err != nil {
+			return Condition{}, err
+		}
+		right, err := parseTerm(strings.TrimSpace(s[i+len(op):]), size)
+		if err != nil {
+			return Condition{}, err
+		}
+		return Condition{left: left, right: right, op: op}, nil
+	}
+	return Condition{}, fmt.Errorf("%q has no comparison, use one of %s", s, strings.Join(comparisons, " "))
+}
+
+// parseTerm parses one side of a condition
+func parseTerm(s string, size int) (term, error) {
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "acc" || lower == "pc":
+		return term{name: lower}, nil
+	case strings.HasPrefix(lower, "mem[") && strings.HasSuffix(lower, "]"):
+		address, err := strconv.Atoi(strings.TrimSpace(s[4 : len(s)-1]))
+		if err != nil || address < 0 || address >= size {
+			return term{}, fmt.Errorf("%q is not a mailbox from mem[0] to mem[%d]", s, size-1)
+		}
+		return term{name: "mem", value: address}, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return term{}, fmt.Errorf("%q is not acc, pc, mem[n] or a number", s)
+	}
+	return term{value: value}, nil
+}
+
+// Holds reports whether the condition is true of the CPU as it is now
+func (c Condition) Holds(cpu *CPU) bool {
+	left, right := c.left.eval(cpu), c.right.eval(cpu)
+	switch c.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<=":
+		return left <= right
+	case ">=":
+		return left >= right
+	case "<":
+		return left < right
+	}
+	return left > right
+}
+
+// eval reads the value of the term from the CPU
+func (t term) eval(cpu *CPU) int {
+	switch t.name {
+	case "acc":
+		return SignedAccumulator(cpu.Accumulator, cpu.Negative)
+	case "pc":
+		return cpu.PC
+	case "mem":
+		return int(cpu.RAM[t.value])
+	}
+	return t.value
+}
+
+// String writes the condition in a standard spelling, so that acc==0 and
+// acc == 0 are the same condition
+func (c Condition) String() string {
+	return fmt.Sprintf("%s %s %s", c.left, c.op, c.right)
+}
+
+func (t term) String() string {
+	switch t.name {
+	case "mem":
+		return fmt.Sprintf("mem[%d]", t.value)
+	case "":
+		return strconv.Itoa(t.value)
+	}
+	return t.name
+}
+
+// BreakCondition returns the first of BreakConditions that holds now,
+// reporting false when none does
+func (c *CPU) BreakCondition() (Condition, bool) {
+	for _, condition := range c.BreakConditions {
+		if condition.Holds(c) {
+			return condition, true
+		}
+	}
+	return Condition{}, false
+}
+
+// AtBreak reports whether a run should stop in front of the next
+// instruction, for a breakpoint on it or one of BreakConditions that holds
+func (c *CPU) AtBreak() bool {
+	if c.Breakpoints[c.PC] {
+		return true
+	}
+	_, ok := c.BreakCondition()
+	return ok
+}
@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		src string
+		// want is the condition in its standard spelling
+		want string
+		err  string
+	}{
+		{src: "acc == 0", want: "acc == 0"},
+		{src: "acc==0", want: "acc == 0"},
+		{src: "ACC != 5", want: "acc != 5"},
+		{src: "mem[9]>5", want: "mem[9] > 5"},
+		{src: "mem[ 9 ] >= 5", want: "mem[9] >= 5"},
+		{src: "pc <= 3", want: "pc <= 3"},
+		{src: "acc < -1", want: "acc < -1"},
+		{src: "5 < mem[0]", want: "5 < mem[0]"},
+		{src: "acc = 5", err: `"acc = 5" has no comparison`},
+		{src: "acc", err: "has no comparison"},
+		{src: "mem[100] == 0", err: `"mem[100]" is not a mailbox from mem[0] to mem[99]`},
+		{src: "mem[x] == 0", err: `"mem[x]" is not a mailbox`},
+		{src: "sp == 0", err: `"sp" is not acc, pc, mem[n] or a number`},
+		{src: "acc == ", err: `"" is not acc, pc, mem[n] or a number`},
+	}
+	for _, test := range tests {
+		condition, err := ParseCondition(test.src, models.MaxSize)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, want %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if got := condition.String(); got != test.want {
+			t.Errorf("%q: parsed as %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestConditionHolds(t *testing.T) {
+	// The accumulator has gone below zero: 998 with the negative flag is -2
+	cpu := New(models.RAM{9: 7})
+	cpu.Accumulator, cpu.Negative, cpu.PC = 998, true, 3
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"acc < 0", true},
+		{"acc == -2", true},
+		{"acc == 998", false},
+		{"pc == 3", true},
+		{"pc != 3", false},
+		{"mem[9] > 5", true},
+		{"mem[9] <= 6", false},
+		{"mem[9] >= 7", true},
+	}
+	for _, test := range tests {
+		condition, err := ParseCondition(test.src, models.MaxSize)
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if got := condition.Holds(cpu); got != test.want {
+			t.Errorf("%q: holds is %v, want %v", test.src, got, test.want)
+		}
+	}
+}
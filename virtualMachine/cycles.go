@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// CycleModel gives each instruction a cost in cycles, for exercises where
+// some instructions, say I/O, are slower than others
+type CycleModel struct {
+	// Costs maps an opcode to its cost. Opcodes left out cost 1
+	Costs map[models.Opcode]int
+}
+
+// Cost is the cycles one execution of opcode takes. A nil model costs every
+// instruction 1 cycle
+func (m *CycleModel) Cost(opcode models.Opcode) int {
+	if m == nil {
+		return 1
+	}
+	if cost, ok := m.Costs[opcode]; ok {
+		return cost
+	}
+	return 1
+}
+
+// ParseCycleModel parses opcode=cost pairs such as INP=5,OUT=5,BRA=1. The
+// extensions of every dialect, such as OTC, can be given a cost too
+func ParseCycleModel(s string) (*CycleModel, error) {
+	model := &CycleModel{Costs: make(map[models.Opcode]int)}
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not opcode=cost", field)
+		}
+
+		opcode := models.Opcode(strings.ToUpper(strings.TrimSpace(parts[0])))
+		if !isOpcode(opcode) {
+			return nil, fmt.Errorf("%q is not an instruction", parts[0])
+		}
+		cost, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || cost < 0 {
+			return nil, fmt.Errorf("cost %q for %s is not a whole number of cycles", parts[1], opcode)
+		}
+		model.Costs[opcode] = cost
+	}
+	return model, nil
+}
+
+// isOpcode reports whether opcode is a standard instruction or an extension
+// in any dialect
+func isOpcode(opcode models.Opcode) bool {
+	if _, ok := models.Opcodes[opcode]; ok {
+		return true
+	}
+	for _, dialect := range models.Dialects {
+		if _, ok := dialect.Extensions[opcode]; ok {
+			return true
+		}
+	}
+	return false
+}
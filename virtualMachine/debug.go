@@ -0,0 +1,52 @@
+package vm
+
+import "context"
+
+// StepN executes up to n instructions. It stops early when the CPU halts, when
+// the next instruction is on a breakpoint, when a break condition holds or
+// when an instruction fails, and returns the steps that were executed
+func (c *CPU) StepN(n int) ([]StepResult, error) {
+	var results []StepResult
+	for i := 0; i < n && !c.Halted; i++ {
+		result, err := c.Step()
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+
+		if c.AtBreak() {
+			break
+		}
+	}
+	return results, nil
+}
+
+// RunUntilBreak runs until the CPU halts, an instruction fails, the next
+// instruction is on a breakpoint or a step leaves a break condition true. A
+// breakpoint on the current instruction does not stop it, so it can be used
+// to continue from one breakpoint to the next
+func (c *CPU) RunUntilBreak() error {
+	return c.RunUntil(func(*CPU) bool { return false })
+}
+
+// RunUntil is RunUntilBreak with an extra stop condition, checked after every step
+func (c *CPU) RunUntil(stop func(*CPU) bool) error {
+	return c.RunUntilContext(context.Background(), stop)
+}
+
+// RunUntilContext is RunUntil that also stops, returning ctx.Err(), when ctx
+// is cancelled
+func (c *CPU) RunUntilContext(ctx context.Context, stop func(*CPU) bool) error {
+	for !c.Halted {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := c.Step(); err != nil {
+			return err
+		}
+		if c.AtBreak() || stop(c) {
+			break
+		}
+	}
+	return nil
+}
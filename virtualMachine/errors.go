@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// The reasons a run can stop with an error. Step returns them wrapped in a
+// *RunError, so test for them with errors.Is
+var (
+	// ErrCycleLimit is returned when the run has used up CPU.MaxCycles
+	ErrCycleLimit = errors.New("cycle limit exceeded")
+	// ErrInvalidOpcode is returned for a mailbox value that is not an instruction
+	ErrInvalidOpcode = errors.New("invalid instruction")
+	// ErrOutputLimit is returned when OUT would go past CPU.MaxOutputs
+	ErrOutputLimit = errors.New("output limit exceeded")
+	// ErrOverflow is returned by CPU.NoWrap when ADD goes past 999
+	ErrOverflow = errors.New("arithmetic overflow")
+	// ErrUnderflow is returned by CPU.NoWrap when SUB goes below 0
+	ErrUnderflow = errors.New("arithmetic underflow")
+	// ErrAddressOutOfRange is returned for an instruction whose operand is a
+	// mailbox past the end of memory
+	ErrAddressOutOfRange = errors.New("address out of range")
+	// ErrInputOutOfRange is returned when INP is given a value outside 0-999
+	ErrInputOutOfRange = errors.New("input out of range")
+)
+
+// RunError is a failure in the middle of a run together with where it happened.
+// Use errors.As to get at it
+type RunError struct {
+	// Err is ErrInputExhausted, ErrCycleLimit, ErrInvalidOpcode,
+	// ErrOutputLimit, ErrOverflow, ErrUnderflow, ErrAddressOutOfRange or
+	// ErrInputOutOfRange
+	Err error
+	// PC is the mailbox of the instruction that failed and Value what it held
+	PC    int
+	Value models.Register
+	// Limit is the limit that was hit, for the cycle and output limits, and
+	// the size of memory for ErrAddressOutOfRange
+	Limit int
+	// Input is the value INP was given, for ErrInputOutOfRange
+	Input int
+}
+
+func (e *RunError) Error() string {
+	switch e.Err {
+	case ErrCycleLimit:
+		return fmt.Sprintf("cycle limit %d exceeded at mailbox %d", e.Limit, e.PC)
+	case ErrInvalidOpcode:
+		return fmt.Sprintf("invalid instruction %03d in mailbox %d", e.Value, e.PC)
+	case ErrOutputLimit:
+		return fmt.Sprintf("output limit %d exceeded", e.Limit)
+	case ErrOverflow:
+		return fmt.Sprintf("overflow: ADD in mailbox %d went past 999", e.PC)
+	case ErrUnderflow:
+		return fmt.Sprintf("underflow: SUB in mailbox %d went below 0", e.PC)
+	case ErrInputExhausted:
+		return fmt.Sprintf("input exhausted at INP in mailbox %d", e.PC)
+	case ErrAddressOutOfRange:
+		instruction := models.Decode(e.Value)
+		return fmt.Sprintf("%s in mailbox %d addresses mailbox %d, outside the %d mailboxes of memory", instruction, e.PC, instruction.Operand, e.Limit)
+	case ErrInputOutOfRange:
+		return fmt.Sprintf("INP in mailbox %d was given %d, outside 0-999", e.PC, e.Input)
+	}
+	return fmt.Sprintf("mailbox %d: %v", e.PC, e.Err)
+}
+
+// Unwrap lets errors.Is match the sentinel error
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// Explain describes in plain English what instr will do when the CPU runs it
+// next, filling in the values currently in its mailboxes and accumulator
+func Explain(instr models.Instruction, cpu *CPU) string {
+	acc := SignedAccumulator(cpu.Accumulator, cpu.Negative)
+	value := int(cpu.RAM[instr.Operand])
+
+	var text string
+	switch instr.Opcode {
+	case models.HLT:
+		text = "stop the program"
+	case models.ADD:
+		text = fmt.Sprintf("add the value in mailbox %d (%d) to the accumulator (%d), giving %d",
+			instr.Operand, value, acc, (cpu.Accumulator+value)%1000)
+	case models.SUB:
+		text = fmt.Sprintf("subtract the value in mailbox %d (%d) from the accumulator (%d), giving %d",
+			instr.Operand, value, acc, cpu.Accumulator-value)
+	case models.STA:
+		text = fmt.Sprintf("store the accumulator (%d) in mailbox %d, replacing %d", cpu.Accumulator, instr.Operand, value)
+	case models.LDA:
+		text = fmt.Sprintf("load the value in mailbox %d (%d) into the accumulator", instr.Operand, value)
+	case models.BRA:
+		text = fmt.Sprintf("branch to mailbox %d", instr.Operand)
+	case models.BRZ:
+		text = fmt.Sprintf("branch to mailbox %d if the accumulator is zero; it is %d, so %s",
+			instr.Operand, acc, branchOutcome(cpu.Accumulator == 0 && !cpu.Negative, instr.Operand, cpu))
+	case models.BRP:
+		text = fmt.Sprintf("branch to mailbox %d if the accumulator is zero or more; it is %d, so %s",
+			instr.Operand, acc, branchOutcome(!cpu.Negative, instr.Operand, cpu))
+	case models.INP:
+		text = "read a value from the in tray into the accumulator"
+	case models.OUT:
+		text = fmt.Sprintf("send the accumulator (%d) to the out tray", cpu.Accumulator)
+	case models.OTC:
+		text = fmt.Sprintf("send the accumulator (%d) to the out tray as the character %q", cpu.Accumulator, rune(cpu.Accumulator))
+	default:
+		code, err := instr.EncodeChecked()
+		if err != nil {
+			return fmt.Sprintf("%s → not an instruction, the program will stop with an error", instr)
+		}
+		return fmt.Sprintf("%03d → not an instruction, the program will stop with an error", code)
+	}
+
+	return fmt.Sprintf("%s → %s", instr, text)
+}
+
+// branchOutcome says where a conditional branch will go
+func branchOutcome(taken bool, target int, cpu *CPU) string {
+	if taken {
+		return fmt.Sprintf("jump to %d", target)
+	}
+	return fmt.Sprintf("carry on to %d", (cpu.PC+1)%cpu.size())
+}
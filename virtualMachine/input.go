@@ -0,0 +1,122 @@
+package vm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// ErrInputExhausted is returned when INP runs and there is no input left to read
+var ErrInputExhausted = errors.New("input exhausted")
+
+// Input supplies the values read by INP. mailbox is the address of the INP
+// instruction asking for the value
+type Input interface {
+	Read(mailbox int) (int, error)
+}
+
+// Values is a fixed list of inputs, used up in order
+type Values []int
+
+// Read takes the next value off the list
+func (v *Values) Read(mailbox int) (int, error) {
+	if len(*v) == 0 {
+		return 0, ErrInputExhausted
+	}
+	value := (*v)[0]
+	*v = (*v)[1:]
+	return value, nil
+}
+
+// RandomValues is n values from 0 to 999 drawn from a generator started at
+// seed, the same values every time for the same seed
+func RandomValues(n int, seed int64) Values {
+	r := rand.New(rand.NewSource(seed))
+	values := make(Values, n)
+	for i := range values {
+		values[i] = r.Intn(1000)
+	}
+	return values
+}
+
+// ReadValues reads whitespace separated values, such as the contents of an
+// input file. The first value that is not from 0 to 999 is reported with its
+// line and position on that line
+func ReadValues(r io.Reader) (Values, error) {
+	var values Values
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		for i, field := range strings.Fields(scanner.Text()) {
+			value, err := ParseValue(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d, value %d: %v", line, i+1, err)
+			}
+			values = append(values, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// LineInput reads one value per line, for example from the user's terminal
+type LineInput struct {
+	// Scanner supplies the lines. It can be shared with other readers of
+	// the same stream, such as the step debugger's command prompt
+	Scanner *bufio.Scanner
+	// Prompt is where the user is asked for each value. When it is nil lines
+	// are read silently and a bad value is an error rather than asked again
+	Prompt io.Writer
+	// Echo, when it is not nil, gets a line such as "INP: 42" for every value
+	// read, so a transcript of the session shows what was typed
+	Echo io.Writer
+}
+
+// NewLineInput reads values from r, prompting on prompt if it is not nil
+func NewLineInput(r io.Reader, prompt io.Writer) *LineInput {
+	return &LineInput{Scanner: bufio.NewScanner(r), Prompt: prompt}
+}
+
+// Read blocks until a line holding a value from 0 to 999 has been read
+func (l *LineInput) Read(mailbox int) (int, error) {
+	for {
+		if l.Prompt != nil {
+			fmt.Fprintf(l.Prompt, "INP [mailbox %d] > ", mailbox)
+		}
+
+		if !l.Scanner.Scan() {
+			if err := l.Scanner.Err(); err != nil {
+				return 0, err
+			}
+			return 0, ErrInputExhausted
+		}
+
+		value, err := ParseValue(strings.TrimSpace(l.Scanner.Text()))
+		if err == nil {
+			if l.Echo != nil {
+				fmt.Fprintf(l.Echo, "INP: %d\n", value)
+			}
+			return value, nil
+		}
+		if l.Prompt == nil {
+			return 0, err
+		}
+		fmt.Fprintln(l.Prompt, err)
+	}
+}
+
+// ParseValue parses a value that fits in a mailbox
+func ParseValue(s string) (int, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil || value < 0 || value > 999 {
+		return 0, fmt.Errorf("%q is not a value from 0 to 999", s)
+	}
+	return value, nil
+}
@@ -0,0 +1,27 @@
+package vm
+
+import (
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/compiler"
+)
+
+// Load assembles LMC source with the default options and returns a CPU ready
+// to run it, with the program attached for its labels and source lines. An
+// assembly error is returned as a *compiler.Error. #include lines are
+// resolved, relative to the working directory, so only Load source you
+// trust; use compiler.Assemble with Options.NoIncludes for source you do
+// not. Use compiler.Assemble and New directly for other options, such as a
+// smaller memory
+func Load(source string) (*CPU, error) {
+	program, err := compiler.Assemble(strings.NewReader(source), compiler.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	cpu := New(program.RAM)
+	cpu.Size = program.Size
+	cpu.Program = program
+	cpu.Dialect = program.Dialect
+	return cpu, nil
+}
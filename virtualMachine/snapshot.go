@@ -0,0 +1,43 @@
+package vm
+
+import "github.com/sparrowTek/LittleManComputer-CLI/models"
+
+// Snapshot is a copy of the parts of the CPU a step can change
+type Snapshot struct {
+	RAM         models.RAM
+	Accumulator int
+	Negative    bool
+}
+
+// Change is one mailbox, or the accumulator, that differs between two snapshots
+type Change struct {
+	// Mailbox is the address that changed, or -1 for the accumulator
+	Mailbox int
+	Before  int
+	After   int
+}
+
+// Snapshot copies the current memory and accumulator
+func (c *CPU) Snapshot() Snapshot {
+	snapshot := Snapshot{RAM: make(models.RAM), Accumulator: c.Accumulator, Negative: c.Negative}
+	c.RAM.Each(func(address int, value models.Register) {
+		snapshot.RAM[address] = value
+	})
+	return snapshot
+}
+
+// Diff lists what changed from before to after, the accumulator first and then
+// mailboxes in address order
+func Diff(before, after Snapshot) []Change {
+	var changes []Change
+	if before.Accumulator != after.Accumulator {
+		changes = append(changes, Change{Mailbox: -1, Before: before.Accumulator, After: after.Accumulator})
+	}
+
+	after.RAM.Each(func(address int, value models.Register) {
+		if before.RAM[address] != value {
+			changes = append(changes, Change{Mailbox: address, Before: int(before.RAM[address]), After: int(value)})
+		}
+	})
+	return changes
+}
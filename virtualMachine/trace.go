@@ -0,0 +1,154 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// TraceRecord is the state after one executed instruction. A trace is a file
+// of these, one JSON object per line, in the order the instructions ran
+type TraceRecord struct {
+	Cycle int `json:"cycle"`
+	PC    int `json:"pc"`
+	// Value is what the mailbox at PC held when the instruction was read,
+	// which can differ from Memory if the program writes over itself
+	Value       models.Register `json:"value"`
+	Instruction string          `json:"instruction"`
+	// Dialect is the name of the instruction set Value is decoded with,
+	// omitted for traces of a CPU with the zero Dialect
+	Dialect string `json:"dialect,omitempty"`
+	// Line is the source line of the instruction, when the CPU knows its
+	// Program
+	Line        int  `json:"line,omitempty"`
+	Accumulator int  `json:"accumulator"`
+	Negative    bool `json:"negative"`
+	Halted      bool `json:"halted"`
+	// Output is the value sent by OUT, nil for every other instruction
+	Output *int              `json:"output,omitempty"`
+	Memory []models.Register `json:"memory"`
+}
+
+// Trace records the step the CPU has just taken
+func (c *CPU) Trace(step StepResult) TraceRecord {
+	record := TraceRecord{
+		Cycle:       c.Cycles,
+		PC:          step.PC,
+		Value:       step.Value,
+		Instruction: step.Instruction.String(),
+		Dialect:     c.Dialect.Name,
+		Accumulator: step.Accumulator,
+		Negative:    step.Negative,
+		Halted:      step.Halted,
+		Memory:      c.Result(nil).Memory,
+	}
+	if c.Program != nil {
+		record.Line = c.Program.Source[step.PC].Line
+	}
+	if step.Instruction.Opcode == models.OUT {
+		output := step.Accumulator
+		record.Output = &output
+	}
+	return record
+}
+
+// Decode is the instruction in Value, decoded with the record's dialect. A
+// record with no dialect, or one ReadTrace would reject, decodes as standard
+func (r TraceRecord) Decode() models.Instruction {
+	dialect, _ := models.LookupDialect(r.Dialect)
+	return dialect.Decode(r.Value)
+}
+
+// ReadTrace reads and checks a trace. Anything that could not have come from
+// a run, such as a skipped cycle or a value that does not fit in a mailbox, is
+// reported with the line it is on
+func ReadTrace(r io.Reader) ([]TraceRecord, error) {
+	var records []TraceRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record TraceRecord
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("line %d: not a trace record: %v", line, err)
+		}
+
+		var previous *TraceRecord
+		if len(records) > 0 {
+			previous = &records[len(records)-1]
+		}
+		if err := checkRecord(record, previous); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("trace is empty")
+	}
+	return records, nil
+}
+
+// checkRecord makes sure record is a possible state and follows on from the
+// record before it
+func checkRecord(record TraceRecord, previous *TraceRecord) error {
+	size := len(record.Memory)
+	switch {
+	case size < 1 || size > models.MaxSize:
+		return fmt.Errorf("memory has %d mailboxes, want 1 to %d", size, models.MaxSize)
+	case record.PC < 0 || record.PC >= size:
+		return fmt.Errorf("pc %d is outside the %d mailboxes of memory", record.PC, size)
+	case record.Accumulator < 0 || record.Accumulator > 999:
+		return fmt.Errorf("accumulator %d is not a value from 0 to 999", record.Accumulator)
+	case record.Value < 0 || record.Value > 999:
+		return fmt.Errorf("value %d is not a value from 0 to 999", record.Value)
+	}
+	if record.Dialect != "" {
+		if _, err := models.LookupDialect(record.Dialect); err != nil {
+			return err
+		}
+	}
+	switch instruction := record.Decode(); {
+	case instruction.String() != record.Instruction:
+		return fmt.Errorf("instruction %q does not match value %03d", record.Instruction, record.Value)
+	case (record.Output != nil) != (instruction.Opcode == models.OUT):
+		return fmt.Errorf("output does not match instruction %q", record.Instruction)
+	}
+	for address, value := range record.Memory {
+		if value < 0 || value > 999 {
+			return fmt.Errorf("mailbox %d holds %d, not a value from 0 to 999", address, value)
+		}
+	}
+
+	if previous == nil {
+		if record.Cycle != 1 {
+			return fmt.Errorf("trace starts at cycle %d, want 1", record.Cycle)
+		}
+		return nil
+	}
+	switch {
+	case previous.Halted:
+		return fmt.Errorf("cycle %d comes after the program halted", record.Cycle)
+	case record.Cycle != previous.Cycle+1:
+		return fmt.Errorf("cycle %d follows cycle %d", record.Cycle, previous.Cycle)
+	case size != len(previous.Memory):
+		return fmt.Errorf("memory has %d mailboxes, earlier records have %d", size, len(previous.Memory))
+	case record.Dialect != previous.Dialect:
+		return fmt.Errorf("dialect %q, earlier records have %q", record.Dialect, previous.Dialect)
+	}
+	return nil
+}
@@ -1,12 +1,397 @@
 // Package vm is the virtual machine where the little man computer runs
 package vm
 
-// Run the vm
-func Run(ram RAM) {
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+// CPU is the little man together with his mailboxes, accumulator and counter
+type CPU struct {
+	RAM         models.RAM
+	PC          int
+	Accumulator int
+	Negative    bool
+	Halted      bool
+	// Cycles counts the instructions executed
+	Cycles int
+	// Cost is the cycles the run has taken under CycleModel. Without a
+	// model every instruction costs 1 and it equals Cycles
+	Cost int
+	// CycleModel weights the cost of each instruction. It may be nil
+	CycleModel *CycleModel
+	// Size is the number of mailboxes. Operands outside it are an error and
+	// the program counter wraps back to 0 at the end of memory. Zero means
+	// the standard 100, as it does for compiler.Options
+	Size int
+	// Program is the assembled program the CPU was loaded from, for its
+	// labels and source lines. It may be nil
+	Program *models.Program
+	// Dialect is the instruction set to decode memory with. The zero Dialect
+	// runs the standard instructions only
+	Dialect models.Dialect
+
+	// Input supplies the values read by INP
+	Input Input
+	// Output receives every value sent by OUT, one per line. Values are
+	// always recorded in Outputs even when Output is nil
+	Output io.Writer
+	// Breakpoints are the mailboxes StepN and RunUntilBreak stop in front of
+	Breakpoints map[int]bool
+	// BreakConditions stop StepN and RunUntilBreak after any step that leaves
+	// one of them true
+	BreakConditions []Condition
+	// MaxOutputs stops the run with an error when an OUT would send more than
+	// this many values. Zero means no limit
+	MaxOutputs int
+	// MaxCycles stops the run with an error before the instruction that would
+	// go past this many cycles. Zero means no limit
+	MaxCycles int
+	// NoWrap makes an ADD past 999 stop the run with ErrOverflow and a SUB
+	// below 0 stop it with ErrUnderflow, for courses that teach overflow as
+	// an error. By default ADD wraps round and SUB sets the negative flag
+	NoWrap bool
+
+	outputs []int
+	ioLog   []IOEvent
+	// executed counts how often the instruction in each mailbox has run
+	executed map[int]int
+	// accesses counts the reads and writes of each mailbox as an operand
+	accesses map[int]Access
+}
+
+// Access is how many times a mailbox was read as the operand of an LDA, ADD
+// or SUB and written by an STA. Running the instruction in a mailbox is not
+// an access; Executed counts that
+type Access struct {
+	Reads  int `json:"reads"`
+	Writes int `json:"writes"`
+}
+
+// The kinds of IOEvent
+const (
+	EventIn  = "in"
+	EventOut = "out"
+	// EventChar is a value sent by OTC as a character. It is not counted
+	// in Outputs
+	EventChar = "char"
+)
+
+// IOEvent is a value read by INP or sent by OUT. Cycle is the number of the
+// instruction that read or sent it, counting from 1, whatever the CycleModel
+type IOEvent struct {
+	Kind  string `json:"kind"`
+	Value int    `json:"value"`
+	Cycle int    `json:"cycle"`
+}
+
+// StepResult describes one executed instruction and the state it left behind
+type StepResult struct {
+	PC int
+	// Value is what the mailbox at PC held. Instruction is it decoded, which
+	// for an extension such as OTC cannot be encoded back
+	Value       models.Register
+	Instruction models.Instruction
+	Accumulator int
+	Negative    bool
+	Halted      bool
+}
+
+// RunResult is the state of the CPU at the end of a run, ready to be encoded as JSON
+type RunResult struct {
+	Outputs []int `json:"outputs"`
+	// IO is every read and write in the order they happened
+	IO          []IOEvent `json:"io"`
+	Accumulator int       `json:"accumulator"`
+	Signed      int       `json:"signedAccumulator"`
+	Negative    bool      `json:"negative"`
+	PC          int       `json:"pc"`
+	Cycles      int       `json:"cycles"`
+	// Instructions is how many instructions ran. Cycles counts the same
+	// unless a cycle model weights them
+	Instructions int               `json:"instructions"`
+	Halted       bool              `json:"halted"`
+	Memory       []models.Register `json:"memory"`
+	Error        string            `json:"error,omitempty"`
+	// Title and Author describe the program that was run, when its source
+	// says. The CPU does not know them, the caller fills them in
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+// SignedAccumulator is the value the accumulator stands for. After a SUB goes
+// below zero the stored value has wrapped round and the negative flag is set,
+// so 995 with the flag set means -5. The stored value is what STA writes
+func SignedAccumulator(acc int, neg bool) int {
+	if neg {
+		return acc - 1000
+	}
+	return acc
+}
+
+// New returns a CPU with the standard 100 mailboxes, ready to run the program
+// in ram from mailbox 0. The program is copied so running it does not change ram
+func New(ram models.RAM) *CPU {
+	cpu := &CPU{RAM: make(models.RAM), Size: models.MaxSize, Breakpoints: make(map[int]bool)}
+	ram.Each(func(address int, value models.Register) {
+		cpu.RAM[address] = value
+	})
+	return cpu
+}
+
+// Outputs returns every value the program has sent to OUT so far
+func (c *CPU) Outputs() []int {
+	return c.outputs
+}
+
+// Executed reports how many times the instruction in each mailbox has run.
+// Mailboxes that have never run are left out
+func (c *CPU) Executed() map[int]int {
+	return c.executed
+}
+
+// AccessStats reports how many times each mailbox has been read and written
+// by instructions. Mailboxes that have never been either are left out
+func (c *CPU) AccessStats() map[int]Access {
+	return c.accesses
+}
+
+// IOLog returns every value read by INP and sent by OUT so far, in the order
+// the program read and wrote them
+func (c *CPU) IOLog() []IOEvent {
+	return c.ioLog
+}
+
+// Result reports the current state of the CPU. err is the error the run
+// stopped with, if any
+func (c *CPU) Result(err error) RunResult {
+	result := RunResult{
+		Outputs:      c.outputs,
+		IO:           c.ioLog,
+		Accumulator:  c.Accumulator,
+		Signed:       SignedAccumulator(c.Accumulator, c.Negative),
+		Negative:     c.Negative,
+		PC:           c.PC,
+		Cycles:       c.Cost,
+		Instructions: c.Cycles,
+		Halted:       c.Halted,
+		Memory:       make([]models.Register, c.size()),
+	}
+	if result.Outputs == nil {
+		result.Outputs = []int{}
+	}
+	if result.IO == nil {
+		result.IO = []IOEvent{}
+	}
+	c.RAM.Each(func(address int, value models.Register) {
+		if address < len(result.Memory) {
+			result.Memory[address] = value
+		}
+	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
 }
 
-// Step to the next register command
-func Step(ram RAM) {
+// Run the vm until it halts or an instruction fails
+func (c *CPU) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext is Run that can be stopped from outside, for example when the
+// user presses Ctrl-C. It returns ctx.Err() once ctx is cancelled
+func (c *CPU) RunContext(ctx context.Context) error {
+	for !c.Halted {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Step to the next register command. Stepping a halted CPU does nothing
+func (c *CPU) Step() (StepResult, error) {
+	return c.StepWith(c.readInput, c.writeOutput)
+}
+
+// StepWith is Step with the I/O of this one instruction handled by the
+// caller instead of Input and Output, for example a GUI that asks for a value
+// only when an INP runs. in is called for an INP and out for an OUT; either
+// may be nil when the instruction cannot need it, and an INP with a nil in has
+// run out of input. Return ErrInputExhausted from in to end the input. OUT
+// values are recorded in Outputs whatever out does, and OTC still writes to
+// Output
+func (c *CPU) StepWith(in func() (int, error), out func(int) error) (StepResult, error) {
+	instruction := c.Dialect.Decode(c.RAM[c.PC])
+	result := StepResult{PC: c.PC, Value: c.RAM[c.PC], Instruction: instruction}
+	if c.Halted {
+		return c.finish(result), nil
+	}
+
+	if c.MaxCycles > 0 && c.Cycles >= c.MaxCycles {
+		return result, c.fail(ErrCycleLimit, c.MaxCycles)
+	}
+
+	size := c.size()
+	next := (c.PC + 1) % size
+	if instruction.Opcode != models.DAT && instruction.Opcode.HasOperand() && instruction.Operand >= size {
+		return result, c.fail(ErrAddressOutOfRange, size)
+	}
+
+	switch instruction.Opcode {
+	case models.HLT:
+		c.Halted = true
+		next = c.PC
+	case models.ADD:
+		sum := c.Accumulator + int(c.load(instruction.Operand))
+		if c.NoWrap && sum > 999 {
+			return result, c.fail(ErrOverflow, 0)
+		}
+		c.Accumulator = sum % 1000
+		c.Negative = false
+	case models.SUB:
+		difference := c.Accumulator - int(c.load(instruction.Operand))
+		if c.NoWrap && difference < 0 {
+			return result, c.fail(ErrUnderflow, 0)
+		}
+		c.Accumulator = difference
+		c.Negative = c.Accumulator < 0
+		if c.Negative {
+			c.Accumulator += 1000
+		}
+	case models.STA:
+		c.store(instruction.Operand, models.Register(c.Accumulator))
+	case models.LDA:
+		c.Accumulator = int(c.load(instruction.Operand))
+		c.Negative = false
+	case models.BRA:
+		next = instruction.Operand
+	case models.BRZ:
+		if c.Accumulator == 0 && !c.Negative {
+			next = instruction.Operand
+		}
+	case models.BRP:
+		if !c.Negative {
+			next = instruction.Operand
+		}
+	case models.INP:
+		if in == nil {
+			return result, c.fail(ErrInputExhausted, 0)
+		}
+		value, err := in()
+		if errors.Is(err, ErrInputExhausted) {
+			return result, c.fail(ErrInputExhausted, 0)
+		}
+		if err != nil {
+			return result, err
+		}
+		if value < 0 || value > 999 {
+			return result, &RunError{Err: ErrInputOutOfRange, PC: c.PC, Value: c.RAM[c.PC], Input: value}
+		}
+		c.Accumulator = value
+		c.Negative = false
+		c.ioLog = append(c.ioLog, IOEvent{Kind: EventIn, Value: value, Cycle: c.Cycles + 1})
+	case models.OUT:
+		if c.MaxOutputs > 0 && len(c.outputs) >= c.MaxOutputs {
+			return result, c.fail(ErrOutputLimit, c.MaxOutputs)
+		}
+		c.outputs = append(c.outputs, c.Accumulator)
+		c.ioLog = append(c.ioLog, IOEvent{Kind: EventOut, Value: c.Accumulator, Cycle: c.Cycles + 1})
+		if out != nil {
+			if err := out(c.Accumulator); err != nil {
+				return result, err
+			}
+		}
+	case models.OTC:
+		c.ioLog = append(c.ioLog, IOEvent{Kind: EventChar, Value: c.Accumulator, Cycle: c.Cycles + 1})
+		if c.Output != nil {
+			if _, err := fmt.Fprintf(c.Output, "%c", rune(c.Accumulator)); err != nil {
+				return result, err
+			}
+		}
+	default:
+		return result, c.fail(ErrInvalidOpcode, 0)
+	}
+
+	if c.executed == nil {
+		c.executed = make(map[int]int)
+	}
+	c.executed[c.PC]++
+	c.PC = next
+	c.Cycles++
+	c.Cost += c.CycleModel.Cost(instruction.Opcode)
+	return c.finish(result), nil
+}
+
+// load reads a mailbox for an instruction, counting the read
+func (c *CPU) load(address int) models.Register {
+	access := c.access(address)
+	access.Reads++
+	c.accesses[address] = access
+	return c.RAM[address]
+}
+
+// store writes a mailbox for an instruction, counting the write
+func (c *CPU) store(address int, value models.Register) {
+	access := c.access(address)
+	access.Writes++
+	c.accesses[address] = access
+	if c.RAM == nil {
+		c.RAM = make(models.RAM)
+	}
+	c.RAM[address] = value
+}
+
+// size is the number of mailboxes, Size or the standard 100 when it is zero
+func (c *CPU) size() int {
+	if c.Size == 0 {
+		return models.MaxSize
+	}
+	return c.Size
+}
+
+// access returns the counts so far for a mailbox
+func (c *CPU) access(address int) Access {
+	if c.accesses == nil {
+		c.accesses = make(map[int]Access)
+	}
+	return c.accesses[address]
+}
+
+// readInput reads the value for an INP from Input
+func (c *CPU) readInput() (int, error) {
+	if c.Input == nil {
+		return 0, ErrInputExhausted
+	}
+	return c.Input.Read(c.PC)
+}
+
+// writeOutput writes a value sent by OUT to Output, one per line
+func (c *CPU) writeOutput(value int) error {
+	if c.Output == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(c.Output, value)
+	return err
+}
+
+// fail builds the error for the instruction at the program counter
+func (c *CPU) fail(err error, limit int) error {
+	return &RunError{Err: err, PC: c.PC, Value: c.RAM[c.PC], Limit: limit}
+}
 
+// finish fills in the state a step left the CPU in
+func (c *CPU) finish(result StepResult) StepResult {
+	result.Accumulator = c.Accumulator
+	result.Negative = c.Negative
+	result.Halted = c.Halted
+	return result
 }
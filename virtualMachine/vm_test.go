@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+)
+
+func TestArithmetic(t *testing.T) {
+	// LDA 4, then ADD 5 or SUB 5 at mailbox 1, then HLT
+	add := models.RAM{0: 504, 1: 105, 2: 0, 4: 600, 5: 500}
+	sub := models.RAM{0: 504, 1: 205, 2: 0, 4: 5, 5: 7}
+	exact := models.RAM{0: 504, 1: 105, 2: 0, 4: 600, 5: 399}
+	zero := models.RAM{0: 504, 1: 205, 2: 0, 4: 7, 5: 7}
+
+	tests := []struct {
+		name     string
+		ram      models.RAM
+		noWrap   bool
+		err      error
+		acc      int
+		negative bool
+	}{
+		{"ADD past 999 wraps", add, false, nil, 100, false},
+		{"SUB below 0 sets the negative flag", sub, false, nil, 998, true},
+		{"ADD past 999 overflows", add, true, ErrOverflow, 600, false},
+		{"SUB below 0 underflows", sub, true, ErrUnderflow, 5, false},
+		{"ADD to exactly 999 is fine", exact, true, nil, 999, false},
+		{"SUB to exactly 0 is fine", zero, true, nil, 0, false},
+	}
+
+	for _, test := range tests {
+		cpu := New(test.ram)
+		cpu.NoWrap = test.noWrap
+		err := cpu.Run()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if cpu.Accumulator != test.acc || cpu.Negative != test.negative {
+			t.Errorf("%s: accumulator %d negative %v, want %d negative %v", test.name, cpu.Accumulator, cpu.Negative, test.acc, test.negative)
+		}
+		if test.err == nil {
+			continue
+		}
+		var runErr *RunError
+		if !errors.As(err, &runErr) || runErr.PC != 1 || cpu.PC != 1 {
+			t.Errorf("%s: error %v should be at mailbox 1, PC is %d", test.name, err, cpu.PC)
+		}
+	}
+}
+
+func TestRangeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ram  models.RAM
+		size int
+		in   Values
+		err  error
+		msg  string
+	}{
+		{"operand past the end of memory", models.RAM{0: 560}, 50, nil, ErrAddressOutOfRange,
+			"LDA 60 in mailbox 0 addresses mailbox 60, outside the 50 mailboxes of memory"},
+		{"INP given more than 999", models.RAM{0: 901}, 0, Values{1000}, ErrInputOutOfRange,
+			"INP in mailbox 0 was given 1000, outside 0-999"},
+	}
+
+	for _, test := range tests {
+		cpu := New(test.ram)
+		cpu.Size = test.size
+		cpu.Input = &test.in
+		err := cpu.Run()
+		var runErr *RunError
+		if !errors.Is(err, test.err) || !errors.As(err, &runErr) || runErr.PC != 0 {
+			t.Errorf("%s: got error %v, want a *RunError at mailbox 0 for %v", test.name, err, test.err)
+			continue
+		}
+		if err.Error() != test.msg {
+			t.Errorf("%s: got message %q, want %q", test.name, err.Error(), test.msg)
+		}
+	}
+}
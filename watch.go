@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sparrowTek/LittleManComputer-CLI/models"
+	vm "github.com/sparrowTek/LittleManComputer-CLI/virtualMachine"
+)
+
+// watchMailboxes are the mailboxes given with -watch-mailbox, which can be
+// used more than once
+var watchMailboxes mailboxList
+
+func init() {
+	flag.Var(&watchMailboxes, "watch-mailbox", "Log a mailbox's value and the cycle every time the run changes it. Repeat to watch several")
+}
+
+// mailboxList is a flag that collects a mailbox address each time it is given
+type mailboxList []int
+
+func (m *mailboxList) String() string {
+	addresses := make([]string, len(*m))
+	for i, address := range *m {
+		addresses[i] = strconv.Itoa(address)
+	}
+	return strings.Join(addresses, ",")
+}
+
+// Set adds a mailbox. Whether it is inside -size is checked once the run starts
+func (m *mailboxList) Set(s string) error {
+	address, err := strconv.Atoi(s)
+	if err != nil || address < 0 || address >= models.MaxSize {
+		return fmt.Errorf("%q is not a mailbox from 0 to %d", s, models.MaxSize-1)
+	}
+	*m = append(*m, address)
+	return nil
+}
+
+// watched keeps the changes to the -watch-mailbox mailboxes
+func watched(changes []vm.Change) []vm.Change {
+	var kept []vm.Change
+	for _, change := range changes {
+		for _, address := range watchMailboxes {
+			if change.Mailbox == address {
+				kept = append(kept, change)
+				break
+			}
+		}
+	}
+	return kept
+}